@@ -0,0 +1,159 @@
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/huabingli/go-common"
+)
+
+var (
+	ErrInvalidFormat = errors.New("apikey: 格式错误")
+	ErrNotFound      = errors.New("apikey: 不存在")
+	ErrInvalidSecret = errors.New("apikey: 密钥错误")
+	ErrExpired       = errors.New("apikey: 已过期")
+	ErrDisabled      = errors.New("apikey: 已停用")
+)
+
+// Key 保存在 KeyStore 中的 API key，只保存密钥的哈希
+type Key struct {
+	// ID 公开的 key ID，可以出现在日志中
+	ID string `json:"id"`
+	// Prefix key 的前缀，用于区分环境或用途，如 "live"、"test"
+	Prefix string `json:"prefix"`
+	// SecretHash 密钥的 SHA-256 摘要（common.SHA256V）
+	SecretHash string `json:"secretHash"`
+	// Name 便于识别的名称，如调用方的服务名
+	Name string `json:"name,omitempty"`
+	// Scopes 授权范围，支持 "*" 和 "orders:*" 形式的通配
+	Scopes []string `json:"scopes,omitempty"`
+	// ExpiresAt 过期时间，零值表示永不过期
+	ExpiresAt time.Time `json:"expiresAt,omitempty"`
+	// Disabled 停用的 key 立即失效
+	Disabled   bool      `json:"disabled,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastUsedAt time.Time `json:"lastUsedAt,omitempty"`
+}
+
+// Generate 生成新的 API key，返回交给调用方的完整 key（只能展示一次）和需要保存的 Key。
+// 完整 key 的格式为 "<prefix>_<id>_<secret>"，prefix 不能包含下划线
+func Generate(prefix string, scopes ...string) (string, *Key, error) {
+	if prefix == "" || strings.Contains(prefix, "_") {
+		return "", nil, fmt.Errorf("%w: 前缀不能为空或包含下划线", ErrInvalidFormat)
+	}
+	idBytes := make([]byte, 8)
+	secretBytes := make([]byte, 32)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", nil, err
+	}
+	if _, err := rand.Read(secretBytes); err != nil {
+		return "", nil, err
+	}
+	id := hex.EncodeToString(idBytes)
+	secret := base64.RawURLEncoding.EncodeToString(secretBytes)
+	key := &Key{
+		ID:         id,
+		Prefix:     prefix,
+		SecretHash: common.SHA256V([]byte(secret)),
+		Scopes:     scopes,
+		CreatedAt:  time.Now(),
+	}
+	return prefix + "_" + id + "_" + secret, key, nil
+}
+
+// Parse 拆分完整 key 为前缀、ID 和密钥
+func Parse(token string) (prefix, id, secret string, err error) {
+	parts := strings.SplitN(token, "_", 3)
+	if len(parts) != 3 || parts[0] == "" || parts[2] == "" {
+		return "", "", "", ErrInvalidFormat
+	}
+	if _, err := hex.DecodeString(parts[1]); err != nil || parts[1] == "" {
+		return "", "", "", ErrInvalidFormat
+	}
+	return parts[0], parts[1], parts[2], nil
+}
+
+// VerifySecret 以常量时间比较密钥的哈希
+func (k *Key) VerifySecret(secret string) bool {
+	hash := common.SHA256V([]byte(secret))
+	return subtle.ConstantTimeCompare([]byte(hash), []byte(k.SecretHash)) == 1
+}
+
+// Expired 判断在 now 时是否已过期
+func (k *Key) Expired(now time.Time) bool {
+	return !k.ExpiresAt.IsZero() && !now.Before(k.ExpiresAt)
+}
+
+// HasScope 判断是否有授权范围，"*" 匹配所有，"orders:*" 匹配 "orders:read" 等
+func (k *Key) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == "*" || s == scope {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(s, "*"); ok && strings.HasPrefix(scope, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasScopes 判断是否有所有授权范围
+func (k *Key) HasScopes(scopes ...string) bool {
+	return !slices.ContainsFunc(scopes, func(s string) bool { return !k.HasScope(s) })
+}
+
+// Authenticate 校验完整 key：格式、存在、前缀、密钥、停用和过期，
+// 返回的错误可以用 errors.Is 判断原因，id 为解析出的 key ID（格式错误时为空），可以用于审计日志
+func Authenticate(ctx context.Context, store KeyStore, token string, now time.Time) (key *Key, id string, err error) {
+	prefix, id, secret, err := Parse(token)
+	if err != nil {
+		return nil, "", err
+	}
+	key, err = store.Get(ctx, id)
+	if err != nil {
+		return nil, id, err
+	}
+	// 前缀不一致与密钥错误同样处理，避免泄露 key 是否存在
+	if key.Prefix != prefix || !key.VerifySecret(secret) {
+		return nil, id, ErrInvalidSecret
+	}
+	if key.Disabled {
+		return nil, id, ErrDisabled
+	}
+	if key.Expired(now) {
+		return nil, id, ErrExpired
+	}
+	return key, id, nil
+}
+
+// keyContextKey context 中保存 API key 的 key
+type keyContextKey struct{}
+
+// NewContext 返回带有 API key 的 context
+func NewContext(ctx context.Context, key *Key) context.Context {
+	return context.WithValue(ctx, keyContextKey{}, key)
+}
+
+// FromContext 获取 context 中的 API key
+func FromContext(ctx context.Context) (*Key, bool) {
+	key, ok := ctx.Value(keyContextKey{}).(*Key)
+	return key, ok && key != nil
+}
+
+// LogAttrs 从 context 中提取日志字段（api_key_id），用于 log.NewHandler 的 ContextExtractor
+func LogAttrs(ctx context.Context) []slog.Attr {
+	key, ok := FromContext(ctx)
+	if !ok {
+		return nil
+	}
+	return []slog.Attr{slog.String("api_key_id", key.ID)}
+}
@@ -0,0 +1,75 @@
+package apikey
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// KeyStore API key 存储，Get 在 key 不存在时返回 ErrNotFound
+type KeyStore interface {
+	Get(ctx context.Context, id string) (*Key, error)
+	Put(ctx context.Context, key *Key) error
+	Delete(ctx context.Context, id string) error
+	// TouchLastUsed 更新最后使用时间，key 不存在时忽略
+	TouchLastUsed(ctx context.Context, id string, t time.Time) error
+}
+
+// MemoryStore 内存中的 KeyStore，适合测试和少量固定的 key
+type MemoryStore struct {
+	mu   sync.RWMutex
+	keys map[string]*Key
+}
+
+// NewMemoryStore 创建内存存储，可以传入初始的 key
+func NewMemoryStore(keys ...*Key) *MemoryStore {
+	s := &MemoryStore{keys: make(map[string]*Key, len(keys))}
+	for _, k := range keys {
+		s.keys[k.ID] = cloneKey(k)
+	}
+	return s
+}
+
+// Get 返回 key 的副本
+func (s *MemoryStore) Get(_ context.Context, id string) (*Key, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	k, ok := s.keys[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return cloneKey(k), nil
+}
+
+// Put 保存 key 的副本
+func (s *MemoryStore) Put(_ context.Context, key *Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[key.ID] = cloneKey(key)
+	return nil
+}
+
+// Delete 删除 key
+func (s *MemoryStore) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.keys, id)
+	return nil
+}
+
+// TouchLastUsed 更新最后使用时间，只会向后更新
+func (s *MemoryStore) TouchLastUsed(_ context.Context, id string, t time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if k, ok := s.keys[id]; ok && t.After(k.LastUsedAt) {
+		k.LastUsedAt = t
+	}
+	return nil
+}
+
+func cloneKey(k *Key) *Key {
+	c := *k
+	c.Scopes = slices.Clone(k.Scopes)
+	return &c
+}
@@ -0,0 +1,465 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/huabingli/go-common/metrics"
+)
+
+// Policy 淘汰策略
+type Policy int
+
+const (
+	// LRU 容量不足时淘汰最久未使用的条目
+	LRU Policy = iota
+	// LFU 在 LRU 的基础上使用 TinyLFU 准入：新条目的访问频率需要高于被淘汰的条目才能写入，
+	// 适合有大量只访问一次的 key 的场景，避免它们把热点条目挤出去
+	LFU
+)
+
+// EvictReason 条目被移除的原因
+type EvictReason int
+
+const (
+	// EvictSize 容量不足被淘汰
+	EvictSize EvictReason = iota
+	// EvictExpired 过期
+	EvictExpired
+	// EvictDeleted 调用 Delete 或 Clear
+	EvictDeleted
+	// EvictReplaced 被新值覆盖
+	EvictReplaced
+)
+
+// String 用于指标的 reason 标签
+func (r EvictReason) String() string {
+	switch r {
+	case EvictSize:
+		return "size"
+	case EvictExpired:
+		return "expired"
+	case EvictDeleted:
+		return "deleted"
+	case EvictReplaced:
+		return "replaced"
+	default:
+		return "unknown"
+	}
+}
+
+// errLoaderPanic 加载函数 panic 时调用方收到的错误
+var errLoaderPanic = errors.New("cache: 加载函数 panic")
+
+// Loader 加载 key 对应的值
+type Loader[K comparable, V any] func(ctx context.Context, key K) (V, error)
+
+// Config 缓存配置
+type Config[K comparable, V any] struct {
+	// MaxSize 所有条目的最大总成本，默认 10000
+	MaxSize int64
+	// Cost 条目的成本，如按字节数限制时返回值的大小，默认每个条目为 1（即按条目数限制）
+	Cost func(key K, value V) int64
+	// TTL 默认有效期，为 0 时不过期，可以通过 SetWithTTL 单独设置
+	TTL time.Duration
+	// Policy 淘汰策略，默认 LRU
+	Policy Policy
+	// RefreshAfter GetOrLoad 命中写入时间超过该值的条目时，在后台重新加载，期间返回旧值；为 0 时不刷新
+	RefreshAfter time.Duration
+	// OnEvict 条目被移除时调用，在锁外执行
+	OnEvict func(key K, value V, reason EvictReason)
+	// Metrics 不为空时输出命中率、加载、淘汰等指标，需要同时设置 Name
+	Metrics *metrics.Registry
+	// Name 指标的 cache 标签
+	Name string
+}
+
+// Cache 进程内的泛型缓存，支持成本上限、TTL、LRU/LFU 淘汰和合并并发加载，可以在多个 goroutine 中同时使用。
+// 过期的条目在读取或淘汰时删除，也可以调用 DeleteExpired 主动清理
+type Cache[K comparable, V any] struct {
+	cfg    Config[K, V]
+	hash   func(K) uint64
+	stats  stats
+	metric *cacheMetrics
+
+	mu     sync.Mutex
+	items  map[K]*entry[K, V]
+	root   entry[K, V] // 哨兵，root.next 为最近使用的条目
+	cost   int64
+	sketch *sketch
+
+	callsMu sync.Mutex
+	calls   map[K]*call[V]
+}
+
+// entry 缓存条目，同时是 LRU 双向链表的节点
+type entry[K comparable, V any] struct {
+	key        K
+	value      V
+	cost       int64
+	expires    time.Time // 零值表示不过期
+	loadedAt   time.Time
+	refreshing bool
+	prev, next *entry[K, V]
+}
+
+// expired 判断条目在 now 时是否已过期
+func (e *entry[K, V]) expired(now time.Time) bool {
+	return !e.expires.IsZero() && !now.Before(e.expires)
+}
+
+// eviction 锁内收集、锁外通知的移除记录
+type eviction[K comparable, V any] struct {
+	key    K
+	value  V
+	reason EvictReason
+}
+
+// call 正在进行的加载
+type call[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+// New 按配置创建缓存
+func New[K comparable, V any](cfg Config[K, V]) *Cache[K, V] {
+	if cfg.MaxSize <= 0 {
+		cfg.MaxSize = 10000
+	}
+	if cfg.Cost == nil {
+		cfg.Cost = func(K, V) int64 { return 1 }
+	}
+	if cfg.Metrics != nil && cfg.Name == "" {
+		panic("cache: 设置 Metrics 时 Name 不能为空")
+	}
+	c := &Cache[K, V]{
+		cfg:   cfg,
+		hash:  newHasher[K](),
+		items: make(map[K]*entry[K, V]),
+		calls: make(map[K]*call[V]),
+	}
+	c.root.prev, c.root.next = &c.root, &c.root
+	if cfg.Policy == LFU {
+		c.sketch = newSketch(cfg.MaxSize)
+	}
+	if cfg.Metrics != nil {
+		c.metric = newCacheMetrics(cfg.Metrics, cfg.Name)
+	}
+	return c
+}
+
+// Get 获取未过期的值
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	value, ok, _ := c.get(key, false)
+	return value, ok
+}
+
+// get 查找条目并标记为最近使用，refresh 为 true 时判断是否需要后台刷新，需要时标记为刷新中并返回该条目
+func (c *Cache[K, V]) get(key K, refresh bool) (value V, ok bool, stale *entry[K, V]) {
+	now := time.Now()
+	var evicted []eviction[K, V]
+	c.mu.Lock()
+	if c.sketch != nil {
+		c.sketch.increment(c.hash(key))
+	}
+	e, found := c.items[key]
+	if found && e.expired(now) {
+		c.unlink(e)
+		evicted = append(evicted, eviction[K, V]{e.key, e.value, EvictExpired})
+		found = false
+	}
+	if found {
+		c.moveToFront(e)
+		value, ok = e.value, true
+		if refresh && c.cfg.RefreshAfter > 0 && !e.refreshing && now.Sub(e.loadedAt) >= c.cfg.RefreshAfter {
+			e.refreshing = true
+			stale = e
+		}
+	}
+	c.mu.Unlock()
+
+	c.stats.record(ok)
+	c.metric.request(ok)
+	c.notify(evicted)
+	return value, ok, stale
+}
+
+// Set 使用默认 TTL 写入，成本超过 MaxSize 或被 LFU 准入拒绝时返回 false
+func (c *Cache[K, V]) Set(key K, value V) bool {
+	return c.SetWithTTL(key, value, c.cfg.TTL)
+}
+
+// SetWithTTL 写入并指定有效期，ttl 为 0 时不过期
+func (c *Cache[K, V]) SetWithTTL(key K, value V, ttl time.Duration) bool {
+	return c.set(key, value, ttl, nil)
+}
+
+// set 写入条目，expect 非空时只在 key 对应的仍然是 expect 时替换，避免后台刷新写回已经删除或替换的 key
+func (c *Cache[K, V]) set(key K, value V, ttl time.Duration, expect *entry[K, V]) bool {
+	cost := c.cfg.Cost(key, value)
+	if cost > c.cfg.MaxSize {
+		c.remove(key, expect)
+		c.stats.rejections.Add(1)
+		c.metric.reject()
+		return false
+	}
+	now := time.Now()
+	e := &entry[K, V]{key: key, value: value, cost: cost, loadedAt: now}
+	if ttl > 0 {
+		e.expires = now.Add(ttl)
+	}
+
+	var evicted []eviction[K, V]
+	c.mu.Lock()
+	var h uint64
+	if c.sketch != nil {
+		h = c.hash(key)
+		c.sketch.increment(h)
+	}
+	old, replacing := c.items[key]
+	if expect != nil && old != expect {
+		c.mu.Unlock()
+		return false
+	}
+	if replacing {
+		c.unlink(old)
+		evicted = append(evicted, eviction[K, V]{old.key, old.value, EvictReplaced})
+	}
+	if !c.makeRoom(cost, h, !replacing, now, &evicted) {
+		c.mu.Unlock()
+		c.stats.rejections.Add(1)
+		c.metric.reject()
+		c.notify(evicted)
+		return false
+	}
+	c.items[key] = e
+	c.pushFront(e)
+	c.cost += cost
+	c.mu.Unlock()
+
+	c.notify(evicted)
+	return true
+}
+
+// makeRoom 从最久未使用的条目开始淘汰，直到可以放下 cost；
+// admit 为 true 且使用 LFU 时，被淘汰的条目中有访问频率不低于新条目的则拒绝写入，不淘汰任何条目
+func (c *Cache[K, V]) makeRoom(cost int64, h uint64, admit bool, now time.Time, evicted *[]eviction[K, V]) bool {
+	need := c.cost + cost - c.cfg.MaxSize
+	if need <= 0 {
+		return true
+	}
+	var victims []*entry[K, V]
+	var freed int64
+	for v := c.root.prev; v != &c.root && freed < need; v = v.prev {
+		victims = append(victims, v)
+		freed += v.cost
+	}
+	if c.sketch != nil && admit {
+		freq := c.sketch.estimate(h)
+		for _, v := range victims {
+			if !v.expired(now) && c.sketch.estimate(c.hash(v.key)) >= freq {
+				return false
+			}
+		}
+	}
+	for _, v := range victims {
+		c.unlink(v)
+		reason := EvictSize
+		if v.expired(now) {
+			reason = EvictExpired
+		}
+		*evicted = append(*evicted, eviction[K, V]{v.key, v.value, reason})
+	}
+	return true
+}
+
+// Delete 删除条目，返回条目是否存在
+func (c *Cache[K, V]) Delete(key K) bool {
+	return c.remove(key, nil)
+}
+
+// remove 删除条目，expect 非空时只在 key 对应的仍然是 expect 时删除
+func (c *Cache[K, V]) remove(key K, expect *entry[K, V]) bool {
+	c.mu.Lock()
+	e, ok := c.items[key]
+	ok = ok && (expect == nil || e == expect)
+	if ok {
+		c.unlink(e)
+	}
+	c.mu.Unlock()
+	if ok {
+		c.notify([]eviction[K, V]{{e.key, e.value, EvictDeleted}})
+	}
+	return ok
+}
+
+// Clear 删除所有条目
+func (c *Cache[K, V]) Clear() {
+	var evicted []eviction[K, V]
+	c.mu.Lock()
+	for e := c.root.next; e != &c.root; e = c.root.next {
+		c.unlink(e)
+		evicted = append(evicted, eviction[K, V]{e.key, e.value, EvictDeleted})
+	}
+	c.mu.Unlock()
+	c.notify(evicted)
+}
+
+// DeleteExpired 删除所有过期的条目，返回删除的条目数
+func (c *Cache[K, V]) DeleteExpired() int {
+	now := time.Now()
+	var evicted []eviction[K, V]
+	c.mu.Lock()
+	for e := c.root.next; e != &c.root; {
+		next := e.next
+		if e.expired(now) {
+			c.unlink(e)
+			evicted = append(evicted, eviction[K, V]{e.key, e.value, EvictExpired})
+		}
+		e = next
+	}
+	c.mu.Unlock()
+	c.notify(evicted)
+	return len(evicted)
+}
+
+// Len 当前的条目数，包含还没有删除的过期条目
+func (c *Cache[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.items)
+}
+
+// Cost 当前的总成本
+func (c *Cache[K, V]) Cost() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cost
+}
+
+// GetOrLoad 获取值，不存在时调用 loader 加载并写入，同一个 key 的并发加载只执行一次，加载失败不缓存。
+// ctx 取消时本次调用返回 ctx.Err()，共享的加载继续执行，其他等待的调用方不受影响。
+// 配置了 RefreshAfter 时，命中的条目超过刷新时间后在后台重新加载，本次调用返回旧值
+func (c *Cache[K, V]) GetOrLoad(ctx context.Context, key K, loader Loader[K, V]) (V, error) {
+	if value, ok, stale := c.get(key, true); ok {
+		if stale != nil {
+			go c.refresh(context.WithoutCancel(ctx), stale, loader)
+		}
+		return value, nil
+	}
+	return c.load(ctx, key, loader)
+}
+
+// load 加载并写入。同一个 key 的加载在独立的 goroutine 中执行，不受任何一个调用方 ctx 取消的影响，
+// 每个调用方只在自己的 ctx 取消时停止等待
+func (c *Cache[K, V]) load(ctx context.Context, key K, loader Loader[K, V]) (V, error) {
+	c.callsMu.Lock()
+	cl, ok := c.calls[key]
+	if !ok {
+		cl = &call[V]{done: make(chan struct{}), err: errLoaderPanic}
+		c.calls[key] = cl
+		go c.runLoad(context.WithoutCancel(ctx), key, loader, cl)
+	}
+	c.callsMu.Unlock()
+
+	select {
+	case <-cl.done:
+		return cl.value, cl.err
+	case <-ctx.Done():
+		var zero V
+		return zero, ctx.Err()
+	}
+}
+
+// runLoad 执行共享的加载，加载函数 panic 时记录日志，等待的调用方收到 errLoaderPanic
+func (c *Cache[K, V]) runLoad(ctx context.Context, key K, loader Loader[K, V], cl *call[V]) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("缓存加载 panic", slog.String("cache", c.cfg.Name), slog.Any("panic", r))
+		}
+		c.callsMu.Lock()
+		delete(c.calls, key)
+		c.callsMu.Unlock()
+		close(cl.done)
+	}()
+
+	value, err := loader(ctx, key)
+	c.stats.recordLoad(err)
+	c.metric.load(err)
+	if err == nil {
+		c.Set(key, value)
+	}
+	cl.value, cl.err = value, err
+}
+
+// refresh 后台重新加载 stale，失败时保留旧值，下次命中时再次尝试；
+// 刷新期间条目被删除、清空或替换时丢弃加载的结果
+func (c *Cache[K, V]) refresh(ctx context.Context, stale *entry[K, V], loader Loader[K, V]) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("缓存后台刷新 panic", slog.String("cache", c.cfg.Name), slog.Any("panic", r))
+		}
+		c.mu.Lock()
+		stale.refreshing = false
+		c.mu.Unlock()
+	}()
+
+	value, err := loader(ctx, stale.key)
+	c.stats.recordLoad(err)
+	c.metric.load(err)
+	if err != nil {
+		slog.Warn("缓存后台刷新失败，继续使用旧值", slog.String("cache", c.cfg.Name), slog.Any("err", err))
+		return
+	}
+	c.set(stale.key, value, c.cfg.TTL, stale)
+}
+
+// notify 调用 OnEvict 并更新统计，在锁外调用
+func (c *Cache[K, V]) notify(evicted []eviction[K, V]) {
+	if c.metric != nil {
+		c.mu.Lock()
+		entries, cost := len(c.items), c.cost
+		c.mu.Unlock()
+		c.metric.size(entries, cost)
+	}
+	for _, ev := range evicted {
+		if ev.reason == EvictSize || ev.reason == EvictExpired {
+			c.stats.evictions.Add(1)
+		}
+		c.metric.evict(ev.reason)
+		if c.cfg.OnEvict != nil {
+			c.cfg.OnEvict(ev.key, ev.value, ev.reason)
+		}
+	}
+}
+
+// unlink 从链表和 map 中删除条目，调用方需要持有锁
+func (c *Cache[K, V]) unlink(e *entry[K, V]) {
+	e.prev.next = e.next
+	e.next.prev = e.prev
+	e.prev, e.next = nil, nil
+	delete(c.items, e.key)
+	c.cost -= e.cost
+}
+
+// pushFront 放到链表头部，调用方需要持有锁
+func (c *Cache[K, V]) pushFront(e *entry[K, V]) {
+	e.prev = &c.root
+	e.next = c.root.next
+	c.root.next.prev = e
+	c.root.next = e
+}
+
+// moveToFront 移动到链表头部，调用方需要持有锁
+func (c *Cache[K, V]) moveToFront(e *entry[K, V]) {
+	if c.root.next == e {
+		return
+	}
+	e.prev.next = e.next
+	e.next.prev = e.prev
+	c.pushFront(e)
+}
@@ -0,0 +1,110 @@
+package cache
+
+import (
+	"fmt"
+	"hash/maphash"
+	"math/bits"
+)
+
+// sketch TinyLFU 使用的 Count-Min Sketch：4 行计数器，每个计数器最大 15，
+// 累计增加到宽度的 10 倍后所有计数器减半，让访问频率随时间衰减
+type sketch struct {
+	rows      [4][]uint8
+	mask      uint64
+	additions int
+	resetAt   int
+}
+
+// sketchSeeds 每一行使用的哈希种子
+var sketchSeeds = [4]uint64{0xc3a5c85c97cb3127, 0xb492b66fbe98f273, 0x9ae16a3b2f90404f, 0xcbf29ce484222325}
+
+// newSketch 创建宽度约为 size 的 4 倍的 sketch，减少哈希冲突，宽度取 2 的幂并限制在 [64, 1<<20]
+func newSketch(size int64) *sketch {
+	size = min(max(size, 16), 1<<18) * 4
+	width := uint64(1) << bits.Len64(uint64(size-1))
+	s := &sketch{mask: width - 1, resetAt: int(width) * 10}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width)
+	}
+	return s
+}
+
+// index 第 i 行中哈希值对应的位置
+func (s *sketch) index(h uint64, i int) uint64 {
+	h = (h ^ sketchSeeds[i]) * 0x9e3779b97f4a7c15
+	return (h ^ h>>32) & s.mask
+}
+
+// increment 增加一次访问
+func (s *sketch) increment(h uint64) {
+	added := false
+	for i := range s.rows {
+		idx := s.index(h, i)
+		if s.rows[i][idx] < 15 {
+			s.rows[i][idx]++
+			added = true
+		}
+	}
+	if added {
+		s.additions++
+		if s.additions >= s.resetAt {
+			s.reset()
+		}
+	}
+}
+
+// estimate 估计的访问次数，取各行计数器的最小值
+func (s *sketch) estimate(h uint64) uint8 {
+	result := uint8(15)
+	for i := range s.rows {
+		result = min(result, s.rows[i][s.index(h, i)])
+	}
+	return result
+}
+
+// reset 所有计数器减半
+func (s *sketch) reset() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+	s.additions /= 2
+}
+
+// hashSeed 进程内的哈希种子
+var hashSeed = maphash.MakeSeed()
+
+// newHasher 返回 K 的哈希函数，字符串和整数直接计算，其他类型按 %#v 格式化后计算
+func newHasher[K comparable]() func(K) uint64 {
+	return func(key K) uint64 {
+		switch k := any(key).(type) {
+		case string:
+			return maphash.String(hashSeed, k)
+		case int:
+			return mix64(uint64(k))
+		case int64:
+			return mix64(uint64(k))
+		case uint64:
+			return mix64(k)
+		case int32:
+			return mix64(uint64(k))
+		case uint32:
+			return mix64(uint64(k))
+		case uint:
+			return mix64(uint64(k))
+		default:
+			return maphash.String(hashSeed, fmt.Sprintf("%#v", k))
+		}
+	}
+}
+
+// mix64 整数的哈希（splitmix64 的最后一步）
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,128 @@
+package cache
+
+import (
+	"sync/atomic"
+
+	"github.com/huabingli/go-common/metrics"
+)
+
+// Stats 缓存的累计统计
+type Stats struct {
+	Hits       uint64 `json:"hits"`
+	Misses     uint64 `json:"misses"`
+	Loads      uint64 `json:"loads"`
+	LoadErrors uint64 `json:"loadErrors"`
+	// Evictions 因容量不足或过期被移除的条目数，不包含 Delete 和覆盖
+	Evictions uint64 `json:"evictions"`
+	// Rejections 成本超过上限或被 LFU 准入拒绝的写入次数
+	Rejections uint64 `json:"rejections"`
+}
+
+// HitRatio 命中率，没有请求时为 0
+func (s Stats) HitRatio() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
+
+// Stats 返回累计统计
+func (c *Cache[K, V]) Stats() Stats {
+	return Stats{
+		Hits:       c.stats.hits.Load(),
+		Misses:     c.stats.misses.Load(),
+		Loads:      c.stats.loads.Load(),
+		LoadErrors: c.stats.loadErrors.Load(),
+		Evictions:  c.stats.evictions.Load(),
+		Rejections: c.stats.rejections.Load(),
+	}
+}
+
+// stats 原子计数器
+type stats struct {
+	hits, misses      atomic.Uint64
+	loads, loadErrors atomic.Uint64
+	evictions         atomic.Uint64
+	rejections        atomic.Uint64
+}
+
+func (s *stats) record(hit bool) {
+	if hit {
+		s.hits.Add(1)
+	} else {
+		s.misses.Add(1)
+	}
+}
+
+func (s *stats) recordLoad(err error) {
+	s.loads.Add(1)
+	if err != nil {
+		s.loadErrors.Add(1)
+	}
+}
+
+// cacheMetrics 一个缓存的指标，所有缓存共用同名的指标，按 cache 标签区分；方法可以在 nil 上调用
+type cacheMetrics struct {
+	hits, misses           *metrics.Counter
+	loadSuccess, loadError *metrics.Counter
+	rejections             *metrics.Counter
+	evictions              *metrics.CounterVec
+	name                   string
+	entries, cost          *metrics.Gauge
+}
+
+func newCacheMetrics(reg *metrics.Registry, name string) *cacheMetrics {
+	requests := reg.NewCounterVec("cache_requests_total", "缓存读取次数", "cache", "result")
+	loads := reg.NewCounterVec("cache_loads_total", "缓存加载次数", "cache", "result")
+	return &cacheMetrics{
+		hits:        requests.With(name, "hit"),
+		misses:      requests.With(name, "miss"),
+		loadSuccess: loads.With(name, "success"),
+		loadError:   loads.With(name, "error"),
+		rejections:  reg.NewCounterVec("cache_rejections_total", "缓存拒绝写入次数", "cache").With(name),
+		evictions:   reg.NewCounterVec("cache_evictions_total", "缓存条目移除次数", "cache", "reason"),
+		name:        name,
+		entries:     reg.NewGaugeVec("cache_entries", "缓存条目数", "cache").With(name),
+		cost:        reg.NewGaugeVec("cache_cost", "缓存条目的总成本", "cache").With(name),
+	}
+}
+
+func (m *cacheMetrics) request(hit bool) {
+	if m == nil {
+		return
+	}
+	if hit {
+		m.hits.Inc()
+	} else {
+		m.misses.Inc()
+	}
+}
+
+func (m *cacheMetrics) load(err error) {
+	if m == nil {
+		return
+	}
+	if err != nil {
+		m.loadError.Inc()
+	} else {
+		m.loadSuccess.Inc()
+	}
+}
+
+func (m *cacheMetrics) reject() {
+	if m != nil {
+		m.rejections.Inc()
+	}
+}
+
+func (m *cacheMetrics) evict(reason EvictReason) {
+	if m != nil {
+		m.evictions.With(m.name, reason.String()).Inc()
+	}
+}
+
+func (m *cacheMetrics) size(entries int, cost int64) {
+	m.entries.Set(float64(entries))
+	m.cost.Set(float64(cost))
+}
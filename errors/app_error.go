@@ -0,0 +1,183 @@
+package errors
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// Definition 错误码定义，描述错误码对应的 HTTP 状态码和面向用户的提示
+type Definition struct {
+	Code       string // 稳定的错误码，对外暴露，不随文案变化
+	Status     int    // HTTP 状态码
+	MessageKey string // 面向用户提示文案的 i18n key
+	Message    string // 默认提示文案，没有翻译时使用
+	Retryable  bool   // 客户端是否可以重试
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Definition{}
+)
+
+// 内置错误码
+var (
+	Internal           = Register(Definition{Code: "INTERNAL", Status: http.StatusInternalServerError, MessageKey: "error.internal", Message: "服务器内部错误"})
+	BadRequest         = Register(Definition{Code: "BAD_REQUEST", Status: http.StatusBadRequest, MessageKey: "error.bad_request", Message: "请求参数错误"})
+	Unauthorized       = Register(Definition{Code: "UNAUTHORIZED", Status: http.StatusUnauthorized, MessageKey: "error.unauthorized", Message: "未登录或登录已过期"})
+	Forbidden          = Register(Definition{Code: "FORBIDDEN", Status: http.StatusForbidden, MessageKey: "error.forbidden", Message: "没有权限"})
+	NotFound           = Register(Definition{Code: "NOT_FOUND", Status: http.StatusNotFound, MessageKey: "error.not_found", Message: "资源不存在"})
+	Conflict           = Register(Definition{Code: "CONFLICT", Status: http.StatusConflict, MessageKey: "error.conflict", Message: "资源冲突"})
+	PreconditionFailed = Register(Definition{Code: "PRECONDITION_FAILED", Status: http.StatusPreconditionFailed, MessageKey: "error.precondition_failed", Message: "资源已被修改"})
+	PreconditionNeeded = Register(Definition{Code: "PRECONDITION_REQUIRED", Status: http.StatusPreconditionRequired, MessageKey: "error.precondition_required", Message: "缺少条件请求头"})
+	PayloadTooLarge    = Register(Definition{Code: "PAYLOAD_TOO_LARGE", Status: http.StatusRequestEntityTooLarge, MessageKey: "error.payload_too_large", Message: "请求体过大"})
+	UnsupportedMedia   = Register(Definition{Code: "UNSUPPORTED_MEDIA_TYPE", Status: http.StatusUnsupportedMediaType, MessageKey: "error.unsupported_media_type", Message: "不支持的请求格式"})
+	Unprocessable      = Register(Definition{Code: "UNPROCESSABLE", Status: http.StatusUnprocessableEntity, MessageKey: "error.unprocessable", Message: "请求无法处理"})
+	TooManyRequests    = Register(Definition{Code: "TOO_MANY_REQUESTS", Status: http.StatusTooManyRequests, MessageKey: "error.too_many_requests", Message: "请求过于频繁", Retryable: true})
+	ServiceUnavailable = Register(Definition{Code: "SERVICE_UNAVAILABLE", Status: http.StatusServiceUnavailable, MessageKey: "error.service_unavailable", Message: "服务暂不可用", Retryable: true})
+	GatewayTimeout     = Register(Definition{Code: "TIMEOUT", Status: http.StatusGatewayTimeout, MessageKey: "error.timeout", Message: "请求处理超时", Retryable: true})
+)
+
+// Register 注册错误码，错误码为空或重复注册会 panic
+func Register(def Definition) Definition {
+	if def.Code == "" {
+		panic("errors: 错误码不能为空")
+	}
+	if def.Status == 0 {
+		def.Status = http.StatusInternalServerError
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, ok := registry[def.Code]; ok {
+		panic(fmt.Sprintf("errors: 错误码 %s 重复注册", def.Code))
+	}
+	registry[def.Code] = def
+	return def
+}
+
+// Lookup 按错误码查找定义
+func Lookup(code string) (Definition, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	def, ok := registry[code]
+	return def, ok
+}
+
+// Definitions 返回所有已注册的错误码，按错误码排序
+func Definitions() []Definition {
+	registryMu.RLock()
+	defs := make([]Definition, 0, len(registry))
+	for _, def := range registry {
+		defs = append(defs, def)
+	}
+	registryMu.RUnlock()
+
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Code < defs[j].Code })
+	return defs
+}
+
+// New 按定义创建 AppError，detail 为内部细节，只写入日志不返回给用户
+func (d Definition) New(detail string) error {
+	return &AppError{Definition: d, Detail: detail, stack: callers(3)}
+}
+
+// Newf 按定义和格式创建 AppError
+func (d Definition) Newf(format string, args ...any) error {
+	return &AppError{Definition: d, Detail: fmt.Sprintf(format, args...), stack: callers(3)}
+}
+
+// Wrap 按定义包装 err，err 为 nil 时返回 nil
+func (d Definition) Wrap(err error, detail string) error {
+	if err == nil {
+		return nil
+	}
+	e := &AppError{Definition: d, Detail: detail, cause: err}
+	if !hasStack(err) {
+		e.stack = callers(3)
+	}
+	return e
+}
+
+// AppError 应用错误，携带错误码定义和内部细节
+type AppError struct {
+	Definition
+	Detail string // 内部细节，不返回给用户
+
+	cause error
+	stack stack
+}
+
+// Error 实现 error 接口
+func (e *AppError) Error() string {
+	msg := e.Code
+	if e.Detail != "" {
+		msg += ": " + e.Detail
+	} else if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	if e.cause != nil {
+		msg += ": " + e.cause.Error()
+	}
+	return msg
+}
+
+// Unwrap 返回被包装的错误
+func (e *AppError) Unwrap() error {
+	return e.cause
+}
+
+// Is 错误码相同的 AppError 视为同一种错误
+func (e *AppError) Is(target error) bool {
+	t, ok := target.(*AppError)
+	return ok && t.Code == e.Code
+}
+
+// StackTrace 返回创建错误时的调用栈
+func (e *AppError) StackTrace() []Frame {
+	return e.stack.frames()
+}
+
+// ErrorCode 返回错误码
+func (e *AppError) ErrorCode() string {
+	return e.Code
+}
+
+// IsCode 判断错误链上是否有指定错误码的错误
+func IsCode(err error, code string) bool {
+	for err != nil {
+		if c, ok := err.(Coder); ok && c.ErrorCode() == code {
+			return true
+		}
+		err = Unwrap(err)
+	}
+	return false
+}
+
+// AsAppError 从错误链上取出 AppError
+func AsAppError(err error) (*AppError, bool) {
+	var appErr *AppError
+	if As(err, &appErr) {
+		return appErr, true
+	}
+	return nil, false
+}
+
+// ToAppError 将任意错误转换为 AppError：
+// 错误链上有 AppError 时直接返回；有已注册的错误码时按该定义包装；否则视为内部错误
+func ToAppError(err error) *AppError {
+	if err == nil {
+		return nil
+	}
+	if appErr, ok := AsAppError(err); ok {
+		return appErr
+	}
+	def := Internal
+	if code := Code(err); code != "" {
+		if d, ok := Lookup(code); ok {
+			def = d
+		}
+	}
+	return &AppError{Definition: def, cause: err}
+}
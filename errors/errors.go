@@ -0,0 +1,282 @@
+package errors
+
+import (
+	stderrors "errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"reflect"
+	"strings"
+)
+
+// StackTracer 能够提供创建时调用栈的错误
+type StackTracer interface {
+	StackTrace() []Frame
+}
+
+// Coder 能够提供错误码的错误
+type Coder interface {
+	ErrorCode() string
+}
+
+// Error 携带调用栈、错误码和键值字段的错误
+type Error struct {
+	msg   string
+	code  string
+	cause error
+	attrs []slog.Attr
+	stack stack
+	// msg 是否已经包含 cause 的描述（Errorf 使用 %w 时为 true）
+	msgHasCause bool
+}
+
+// New 创建错误并记录调用栈
+func New(msg string) error {
+	return &Error{msg: msg, stack: callers(3)}
+}
+
+// Errorf 按格式创建错误并记录调用栈，支持 %w 包装其他错误
+func Errorf(format string, args ...any) error {
+	err := fmt.Errorf(format, args...)
+	e := &Error{msg: err.Error(), stack: callers(3), msgHasCause: true}
+	switch u := err.(type) {
+	case interface{ Unwrap() error }:
+		e.cause = u.Unwrap()
+	case interface{ Unwrap() []error }:
+		e.cause = stderrors.Join(u.Unwrap()...)
+	}
+	return e
+}
+
+// Wrap 使用 msg 包装 err 并记录调用栈，err 为 nil 时返回 nil
+func Wrap(err error, msg string) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{msg: msg, cause: err, stack: callers(3)}
+}
+
+// Wrapf 按格式包装 err 并记录调用栈，err 为 nil 时返回 nil
+func Wrapf(err error, format string, args ...any) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{msg: fmt.Sprintf(format, args...), cause: err, stack: callers(3)}
+}
+
+// WithCode 为 err 设置错误码，err 为 nil 时返回 nil
+func WithCode(err error, code string) error {
+	if err == nil {
+		return nil
+	}
+	e := annotate(err)
+	e.code = code
+	return e
+}
+
+// WithFields 为 err 附加键值字段，参数格式与 slog 相同，err 为 nil 时返回 nil
+func WithFields(err error, args ...any) error {
+	if err == nil {
+		return nil
+	}
+	e := annotate(err)
+	e.attrs = append(e.attrs[:len(e.attrs):len(e.attrs)], argsToAttrs(args)...)
+	return e
+}
+
+// annotate 在 err 外包装一层用于附加错误码和字段，不修改 err 本身以保证 errors.Is 仍然可用，
+// 如果错误链上已经有调用栈则不再重复记录
+func annotate(err error) *Error {
+	e := &Error{cause: err}
+	if !hasStack(err) {
+		e.stack = callers(4)
+	}
+	return e
+}
+
+// hasStack 判断错误链上是否已经记录了调用栈
+func hasStack(err error) bool {
+	for err != nil {
+		if _, ok := err.(StackTracer); ok {
+			return true
+		}
+		err = stderrors.Unwrap(err)
+	}
+	return false
+}
+
+// Error 实现 error 接口
+func (e *Error) Error() string {
+	switch {
+	case e.cause == nil || e.msgHasCause:
+		return e.msg
+	case e.msg == "":
+		return e.cause.Error()
+	default:
+		return e.msg + ": " + e.cause.Error()
+	}
+}
+
+// Unwrap 返回被包装的错误，供 errors.Is / errors.As 使用
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// StackTrace 返回创建错误时的调用栈
+func (e *Error) StackTrace() []Frame {
+	return e.stack.frames()
+}
+
+// ErrorCode 返回错误码
+func (e *Error) ErrorCode() string {
+	return e.code
+}
+
+// Fields 返回附加在当前错误上的键值字段
+func (e *Error) Fields() []slog.Attr {
+	return e.attrs
+}
+
+// Format 实现 fmt.Formatter，%+v 会输出整条错误链的调用栈
+func (e *Error) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		if s.Flag('+') {
+			_, _ = io.WriteString(s, e.Error())
+			for _, c := range Chain(e) {
+				if len(c.Stack) == 0 {
+					continue
+				}
+				_, _ = fmt.Fprintf(s, "\n%s", c.Message)
+				for _, f := range c.Stack {
+					_, _ = fmt.Fprintf(s, "\n\t%s\n\t\t%s:%d", f.Function, f.File, f.Line)
+				}
+			}
+			return
+		}
+		_, _ = io.WriteString(s, e.Error())
+	case 's':
+		_, _ = io.WriteString(s, e.Error())
+	case 'q':
+		_, _ = fmt.Fprintf(s, "%q", e.Error())
+	}
+}
+
+// Code 返回错误链上第一个非空的错误码
+func Code(err error) string {
+	for err != nil {
+		if c, ok := err.(Coder); ok {
+			if code := c.ErrorCode(); code != "" {
+				return code
+			}
+		}
+		err = stderrors.Unwrap(err)
+	}
+	return ""
+}
+
+// Fields 返回错误链上所有的键值字段，外层在前
+func Fields(err error) []slog.Attr {
+	var attrs []slog.Attr
+	for err != nil {
+		if f, ok := err.(interface{ Fields() []slog.Attr }); ok {
+			attrs = append(attrs, f.Fields()...)
+		}
+		err = stderrors.Unwrap(err)
+	}
+	return attrs
+}
+
+// StackTrace 返回错误链上最内层（最接近出错位置）的调用栈，没有则返回 nil
+func StackTrace(err error) []Frame {
+	var frames []Frame
+	for _, c := range Chain(err) {
+		if len(c.Stack) > 0 {
+			frames = c.Stack
+		}
+	}
+	return frames
+}
+
+// Cause 错误链中的一层
+type Cause struct {
+	Message string  `json:"msg"`
+	Type    string  `json:"type"`
+	Code    string  `json:"code,omitempty"`
+	Stack   []Frame `json:"stack,omitempty"`
+}
+
+// Chain 按从外到内的顺序展开错误链，errors.Join 的每个分支依次展开
+func Chain(err error) []Cause {
+	var chain []Cause
+	var walk func(err error)
+	walk = func(err error) {
+		for err != nil {
+			// errors.Join 这一层本身没有额外信息，直接展开各分支
+			if multi, ok := err.(interface{ Unwrap() []error }); ok {
+				for _, e := range multi.Unwrap() {
+					walk(e)
+				}
+				return
+			}
+
+			next := stderrors.Unwrap(err)
+			// 仅附加错误码或字段的包装层不单独展示
+			if e, ok := err.(*Error); ok && e.msg == "" && len(e.stack) == 0 {
+				err = next
+				continue
+			}
+			c := Cause{
+				Message: ownMessage(err, next),
+				Type:    reflect.TypeOf(err).String(),
+			}
+			if coder, ok := err.(Coder); ok {
+				c.Code = coder.ErrorCode()
+			}
+			if st, ok := err.(StackTracer); ok {
+				c.Stack = st.StackTrace()
+			}
+			chain = append(chain, c)
+			err = next
+		}
+	}
+	walk(err)
+	return chain
+}
+
+// ownMessage 返回 err 自身的描述，去掉被包装错误的部分
+func ownMessage(err, next error) string {
+	if e, ok := err.(*Error); ok && !e.msgHasCause {
+		return e.msg
+	}
+	msg := err.Error()
+	if next != nil {
+		msg = strings.TrimSuffix(msg, ": "+next.Error())
+	}
+	return msg
+}
+
+// argsToAttrs 将 slog 风格的参数转换为 []slog.Attr
+func argsToAttrs(args []any) []slog.Attr {
+	return slog.Group("", args...).Value.Group()
+}
+
+// Is 同标准库 errors.Is
+func Is(err, target error) bool {
+	return stderrors.Is(err, target)
+}
+
+// As 同标准库 errors.As
+func As(err error, target any) bool {
+	return stderrors.As(err, target)
+}
+
+// Unwrap 同标准库 errors.Unwrap
+func Unwrap(err error) error {
+	return stderrors.Unwrap(err)
+}
+
+// Join 同标准库 errors.Join
+func Join(errs ...error) error {
+	return stderrors.Join(errs...)
+}
@@ -0,0 +1,55 @@
+package errors
+
+import (
+	"runtime"
+)
+
+// maxStackDepth 单个错误最多记录的调用栈深度
+const maxStackDepth = 32
+
+// Frame 调用栈中的一帧
+type Frame struct {
+	Function string `json:"function"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+}
+
+// stack 创建错误时记录的程序计数器，只在需要输出时才解析为 Frame
+type stack []uintptr
+
+// callers 记录当前调用栈，skip 为需要跳过的栈帧数（含 runtime.Callers 本身）
+func callers(skip int) stack {
+	var pcs [maxStackDepth]uintptr
+	n := runtime.Callers(skip, pcs[:])
+	s := make(stack, n)
+	copy(s, pcs[:n])
+	return s
+}
+
+// frames 将程序计数器解析为可读的栈帧
+func (s stack) frames() []Frame {
+	if len(s) == 0 {
+		return nil
+	}
+	result := make([]Frame, 0, len(s))
+	frames := runtime.CallersFrames(s)
+	for {
+		frame, more := frames.Next()
+		result = append(
+			result, Frame{
+				Function: frame.Function,
+				File:     frame.File,
+				Line:     frame.Line,
+			},
+		)
+		if !more {
+			break
+		}
+	}
+	return result
+}
+
+// Callers 返回当前调用栈，skip 为 0 时第一帧是 Callers 的调用者
+func Callers(skip int) []Frame {
+	return callers(skip + 3).frames()
+}
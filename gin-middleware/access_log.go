@@ -0,0 +1,219 @@
+/**
+  @author: 35840
+  @date: 2026/10/19
+  @desc: GSlog 访问日志格式：NCSA common/combined 和 nginx 风格的自定义模板
+**/
+
+package middleware
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huabingli/go-common"
+)
+
+// AccessLogFormat 访问日志格式
+type AccessLogFormat int
+
+const (
+	FormatSlog     AccessLogFormat = iota // 结构化 slog 记录（默认）
+	FormatCommon                          // NCSA common log format
+	FormatCombined                        // NCSA combined log format
+	FormatTemplate                        // 使用 GSlogConfig.Template 自定义
+)
+
+const (
+	// CommonLogTemplate NCSA common log format 对应的模板
+	CommonLogTemplate = `$remote_addr - $remote_user [$time_local] "$request" $status $body_bytes_sent`
+	// CombinedLogTemplate NCSA combined log format 对应的模板，与 nginx 默认的 combined 格式相同
+	CombinedLogTemplate = CommonLogTemplate + ` "$http_referer" "$http_user_agent"`
+)
+
+// phaseVarPrefix 单个阶段耗时的模板变量前缀，如 $phase_db
+const phaseVarPrefix = "phase_"
+
+// accessEntry 渲染访问日志需要的请求信息
+type accessEntry struct {
+	c        *gin.Context
+	start    time.Time
+	duration time.Duration
+	status   int
+	timing   *common.RequestTiming // 未开启 GSlogConfig.Timing 时为空
+	slow     bool
+}
+
+// accessVarFunc 模板变量的取值函数
+type accessVarFunc func(e *accessEntry) string
+
+// accessVars 支持的模板变量，$http_xxx 会读取对应的请求头
+var accessVars = map[string]accessVarFunc{
+	"remote_addr": func(e *accessEntry) string { return e.c.ClientIP() },
+	"remote_user": func(e *accessEntry) string {
+		user, _, _ := e.c.Request.BasicAuth()
+		return user
+	},
+	"time_local":   func(e *accessEntry) string { return e.start.Format("02/Jan/2006:15:04:05 -0700") },
+	"time_iso8601": func(e *accessEntry) string { return e.start.Format(time.RFC3339) },
+	"request": func(e *accessEntry) string {
+		return e.c.Request.Method + " " + e.c.Request.RequestURI + " " + e.c.Request.Proto
+	},
+	"request_method":  func(e *accessEntry) string { return e.c.Request.Method },
+	"request_uri":     func(e *accessEntry) string { return e.c.Request.RequestURI },
+	"uri":             func(e *accessEntry) string { return e.c.Request.URL.Path },
+	"args":            func(e *accessEntry) string { return e.c.Request.URL.RawQuery },
+	"server_protocol": func(e *accessEntry) string { return e.c.Request.Proto },
+	"scheme": func(e *accessEntry) string {
+		if e.c.Request.TLS != nil {
+			return "https"
+		}
+		return "http"
+	},
+	"host":            func(e *accessEntry) string { return e.c.Request.Host },
+	"status":          func(e *accessEntry) string { return strconv.Itoa(e.status) },
+	"body_bytes_sent": func(e *accessEntry) string { return strconv.Itoa(max(e.c.Writer.Size(), 0)) },
+	"request_length": func(e *accessEntry) string {
+		return strconv.FormatInt(max(e.c.Request.ContentLength, 0), 10)
+	},
+	"request_time": func(e *accessEntry) string { return fmt.Sprintf("%.3f", e.duration.Seconds()) },
+	"request_id":   func(e *accessEntry) string { return GetRequestID(e.c) },
+	"route":        func(e *accessEntry) string { return e.c.FullPath() },
+	"timing": func(e *accessEntry) string {
+		if e.timing == nil {
+			return ""
+		}
+		phases := e.timing.Phases()
+		parts := make([]string, 0, len(phases))
+		for _, p := range phases {
+			parts = append(parts, p.Name+"="+formatMillis(p.Duration))
+		}
+		return strings.Join(parts, ",")
+	},
+	"slow": func(e *accessEntry) string {
+		if e.timing == nil {
+			return ""
+		}
+		return strconv.FormatBool(e.slow)
+	},
+}
+
+// accessSegment 模板片段，literal 和 value 二选一
+type accessSegment struct {
+	literal string
+	value   accessVarFunc
+}
+
+// accessTemplate 编译后的访问日志模板
+type accessTemplate []accessSegment
+
+// compileAccessTemplate 编译 nginx 风格的模板，未知变量输出 "-"
+func compileAccessTemplate(tmpl string) accessTemplate {
+	var segments accessTemplate
+	var literal strings.Builder
+	for i := 0; i < len(tmpl); i++ {
+		if tmpl[i] != '$' {
+			literal.WriteByte(tmpl[i])
+			continue
+		}
+		j := i + 1
+		for j < len(tmpl) && isVarChar(tmpl[j]) {
+			j++
+		}
+		if j == i+1 {
+			literal.WriteByte('$')
+			continue
+		}
+		if literal.Len() > 0 {
+			segments = append(segments, accessSegment{literal: literal.String()})
+			literal.Reset()
+		}
+		segments = append(segments, accessSegment{value: lookupAccessVar(tmpl[i+1 : j])})
+		i = j - 1
+	}
+	if literal.Len() > 0 {
+		segments = append(segments, accessSegment{literal: literal.String()})
+	}
+	return segments
+}
+
+// lookupAccessVar 查找模板变量
+func lookupAccessVar(name string) accessVarFunc {
+	if fn, ok := accessVars[name]; ok {
+		return fn
+	}
+	if header, ok := strings.CutPrefix(name, "http_"); ok {
+		header = strings.ReplaceAll(header, "_", "-")
+		return func(e *accessEntry) string { return e.c.GetHeader(header) }
+	}
+	if phase, ok := strings.CutPrefix(name, phaseVarPrefix); ok {
+		return func(e *accessEntry) string { return phaseMillis(e.timing, phase) }
+	}
+	return func(*accessEntry) string { return "" }
+}
+
+// render 渲染一行访问日志，空值输出 "-"，双引号和控制字符转义为 \xHH
+func (t accessTemplate) render(e *accessEntry) string {
+	var sb strings.Builder
+	for _, seg := range t {
+		if seg.value == nil {
+			sb.WriteString(seg.literal)
+			continue
+		}
+		v := seg.value(e)
+		if v == "" {
+			sb.WriteByte('-')
+			continue
+		}
+		for i := 0; i < len(v); i++ {
+			ch := v[i]
+			if ch == '"' || ch == '\\' || ch < 0x20 || ch == 0x7f {
+				fmt.Fprintf(&sb, `\x%02X`, ch)
+				continue
+			}
+			sb.WriteByte(ch)
+		}
+	}
+	return sb.String()
+}
+
+// phaseMillis 返回阶段耗时的毫秒数，没有该阶段时返回空字符串
+func phaseMillis(timing *common.RequestTiming, name string) string {
+	if timing == nil {
+		return ""
+	}
+	for _, p := range timing.Phases() {
+		if p.Name == name {
+			return formatMillis(p.Duration)
+		}
+	}
+	return ""
+}
+
+// formatMillis 格式化为毫秒数
+func formatMillis(d time.Duration) string {
+	return strconv.FormatFloat(durationMillis(d), 'f', -1, 64)
+}
+
+func isVarChar(ch byte) bool {
+	return ch == '_' || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9'
+}
+
+// accessLineWriter 串行写入访问日志行
+type accessLineWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (w *accessLineWriter) writeLine(line string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, err := io.WriteString(w.w, line+"\n"); err != nil {
+		slog.Error("写入访问日志失败", slog.Any("err", err))
+	}
+}
@@ -0,0 +1,176 @@
+/**
+  @author: 35840
+  @date: 2026/10/19
+  @desc: API key 认证中间件，支持授权范围、过期和最后使用时间
+**/
+
+package middleware
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huabingli/go-common"
+	"github.com/huabingli/go-common/apikey"
+	cerrors "github.com/huabingli/go-common/errors"
+)
+
+// apiKeyContextKey gin.Context 中保存 API key 的 key
+const apiKeyContextKey = "_go-common/apiKey"
+
+// APIKeyConfig API key 认证中间件配置
+type APIKeyConfig struct {
+	// Store key 存储，必填
+	Store apikey.KeyStore
+	// Header 读取 key 的 header，默认 DefaultAPIKeyHeader；header 为空时也接受 "Authorization: ApiKey <key>"
+	Header string
+	// Scopes 要求 key 拥有的全部授权范围，路由级别的要求可以使用 RequireScopes
+	Scopes []string
+	// Skip 返回 true 时不认证
+	Skip func(c *gin.Context) bool
+	// LastUsedInterval 最后使用时间的更新间隔，避免每个请求都写存储，默认 1 分钟
+	LastUsedInterval time.Duration
+}
+
+// APIKey 要求请求携带有效的 API key，并拥有 scopes 中的全部授权范围
+func APIKey(store apikey.KeyStore, scopes ...string) gin.HandlerFunc {
+	return APIKeyWithConfig(APIKeyConfig{Store: store, Scopes: scopes})
+}
+
+// APIKeyWithConfig 按配置创建 API key 认证中间件。
+// key 无效时响应 401，授权范围不足时响应 403，失败的尝试通过 slog 记录审计日志，日志中只有 key ID，不会出现密钥
+func APIKeyWithConfig(cfg APIKeyConfig) gin.HandlerFunc {
+	if cfg.Store == nil {
+		panic("middleware: APIKeyConfig.Store 不能为空")
+	}
+	if cfg.Header == "" {
+		cfg.Header = DefaultAPIKeyHeader
+	}
+	if cfg.LastUsedInterval <= 0 {
+		cfg.LastUsedInterval = time.Minute
+	}
+
+	return func(c *gin.Context) {
+		if cfg.Skip != nil && cfg.Skip(c) {
+			c.Next()
+			return
+		}
+
+		token := c.GetHeader(cfg.Header)
+		if token == "" {
+			if scheme, rest, ok := strings.Cut(c.GetHeader("Authorization"), " "); ok && strings.EqualFold(scheme, "ApiKey") {
+				token = strings.TrimSpace(rest)
+			}
+		}
+		if token == "" {
+			auditAPIKey(c, "", "missing")
+			AbortWithProblem(c, NewProblem(cerrors.Unauthorized))
+			return
+		}
+
+		now := time.Now()
+		key, id, err := apikey.Authenticate(c.Request.Context(), cfg.Store, token, now)
+		if err != nil {
+			auditAPIKey(c, id, apiKeyFailureReason(err))
+			if !isAPIKeyRejection(err) {
+				// 存储故障不是 key 无效，响应 503，避免客户端误以为 key 失效而丢弃或轮换
+				slog.ErrorContext(c.Request.Context(), "读取 API key 失败", slog.String("keyId", id), slog.Any("err", err))
+				AbortWithProblem(c, NewProblem(cerrors.ServiceUnavailable))
+				return
+			}
+			AbortWithProblem(c, NewProblem(cerrors.Unauthorized))
+			return
+		}
+		if !key.HasScopes(cfg.Scopes...) {
+			auditAPIKey(c, key.ID, "scope")
+			AbortWithProblem(c, NewProblem(cerrors.Forbidden))
+			return
+		}
+
+		if now.Sub(key.LastUsedAt) >= cfg.LastUsedInterval {
+			ctx := context.WithoutCancel(c.Request.Context())
+			common.SafeGo(
+				func() {
+					if err := cfg.Store.TouchLastUsed(ctx, key.ID, now); err != nil {
+						slog.WarnContext(ctx, "更新 API key 最后使用时间失败", slog.String("keyId", key.ID), slog.Any("err", err))
+					}
+				}, nil,
+			)
+		}
+
+		AddLogAttrs(c, slog.Group("apiKey", slog.String("id", key.ID), slog.String("name", key.Name)))
+		c.Set(apiKeyContextKey, key)
+		c.Request = c.Request.WithContext(apikey.NewContext(c.Request.Context(), key))
+		c.Next()
+	}
+}
+
+// RequireScopes 要求 APIKey 中间件认证的 key 拥有全部授权范围，用于路由或路由组
+func RequireScopes(scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key, ok := GetAPIKey(c)
+		if !ok {
+			AbortWithProblem(c, NewProblem(cerrors.Unauthorized))
+			return
+		}
+		if !key.HasScopes(scopes...) {
+			auditAPIKey(c, key.ID, "scope")
+			AbortWithProblem(c, NewProblem(cerrors.Forbidden))
+			return
+		}
+		c.Next()
+	}
+}
+
+// GetAPIKey 获取 APIKey 中间件认证通过的 key
+func GetAPIKey(c *gin.Context) (*apikey.Key, bool) {
+	if val, ok := c.Get(apiKeyContextKey); ok {
+		key, ok := val.(*apikey.Key)
+		return key, ok
+	}
+	return nil, false
+}
+
+// auditAPIKey 记录认证失败的审计日志，只记录 key ID
+func auditAPIKey(c *gin.Context, keyID, reason string) {
+	slog.WarnContext(
+		c.Request.Context(), "API key 认证失败",
+		slog.String("keyId", keyID),
+		slog.String("reason", reason),
+		slog.String("ip", c.ClientIP()),
+		slog.String("method", c.Request.Method),
+		slog.String("path", c.Request.URL.Path),
+	)
+	AddLogAttrs(c, slog.Group("apiKey", slog.String("id", keyID), slog.String("rejected", reason)))
+}
+
+// isAPIKeyRejection 判断是否为 key 本身的问题，其余为存储错误
+func isAPIKeyRejection(err error) bool {
+	return errors.Is(err, apikey.ErrInvalidFormat) ||
+		errors.Is(err, apikey.ErrNotFound) ||
+		errors.Is(err, apikey.ErrInvalidSecret) ||
+		errors.Is(err, apikey.ErrDisabled) ||
+		errors.Is(err, apikey.ErrExpired)
+}
+
+// apiKeyFailureReason 审计日志中的失败原因
+func apiKeyFailureReason(err error) string {
+	switch {
+	case errors.Is(err, apikey.ErrInvalidFormat):
+		return "format"
+	case errors.Is(err, apikey.ErrNotFound):
+		return "not_found"
+	case errors.Is(err, apikey.ErrInvalidSecret):
+		return "secret"
+	case errors.Is(err, apikey.ErrDisabled):
+		return "disabled"
+	case errors.Is(err, apikey.ErrExpired):
+		return "expired"
+	default:
+		return "store_error"
+	}
+}
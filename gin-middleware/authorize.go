@@ -0,0 +1,258 @@
+/**
+  @author: 35840
+  @date: 2026/10/19
+  @desc: 基于角色和权限的授权中间件，按路由模板和方法配置策略
+**/
+
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	cerrors "github.com/huabingli/go-common/errors"
+	"github.com/huabingli/go-common/jwt"
+	"github.com/huabingli/go-common/rbac"
+)
+
+// subjectContextKey gin.Context 中保存授权主体的 key
+const subjectContextKey = "_go-common/subject"
+
+// PolicyPredicate 自定义的授权判断，如租户归属，Name 用于日志和策略列表
+type PolicyPredicate struct {
+	Name  string
+	Check func(c *gin.Context, sub rbac.Subject) bool
+}
+
+// MatchParam 要求路由参数 param 与主体属性 attribute 相等，如 MatchParam("tenantId", "tenant")
+func MatchParam(param, attribute string) PolicyPredicate {
+	return PolicyPredicate{
+		Name: "param:" + param + "==" + attribute,
+		Check: func(c *gin.Context, sub rbac.Subject) bool {
+			value, ok := sub.Attributes[attribute]
+			if !ok {
+				return false
+			}
+			s, ok := value.(string)
+			return ok && s != "" && s == c.Param(param)
+		},
+	}
+}
+
+// RoutePolicy 一个路由的授权策略
+type RoutePolicy struct {
+	// Method 请求方法，为空或 "*" 表示所有方法，同一路由上指定了方法的策略优先
+	Method string
+	// Path gin 的路由模板，与 c.FullPath() 一致，如 "/orders/:id"
+	Path string
+	// Permissions 需要的全部权限
+	Permissions []rbac.Permission
+	// Predicates 权限满足后还需要通过的全部自定义判断
+	Predicates []PolicyPredicate
+	// Public 不需要认证和授权
+	Public bool
+}
+
+// AuthorizeConfig 授权中间件配置
+type AuthorizeConfig struct {
+	// RBAC 角色和权限表，必填
+	RBAC *rbac.RBAC
+	// Policies 路由策略表
+	Policies []RoutePolicy
+	// Subject 获取请求主体，默认 DefaultSubject
+	Subject func(c *gin.Context) (rbac.Subject, bool)
+	// DenyByDefault 没有配置策略的路由拒绝访问，默认放行
+	DenyByDefault bool
+	// DryRun 只记录本应拒绝的请求，不实际拒绝，用于上线前验证策略
+	DryRun bool
+}
+
+// policyKey 策略表的 key
+type policyKey struct {
+	method string
+	path   string
+}
+
+// Authorize 按配置创建授权中间件，需要放在认证中间件之后。
+// 没有主体时响应 401，权限不足或自定义判断不通过时响应 403，决策结果以 "authz" 分组附加到 GSlog 的访问日志
+func Authorize(cfg AuthorizeConfig) gin.HandlerFunc {
+	if cfg.RBAC == nil {
+		panic("middleware: AuthorizeConfig.RBAC 不能为空")
+	}
+	if cfg.Subject == nil {
+		cfg.Subject = DefaultSubject
+	}
+	table := make(map[policyKey]*RoutePolicy, len(cfg.Policies))
+	for i := range cfg.Policies {
+		p := &cfg.Policies[i]
+		table[policyKey{method: policyMethod(p.Method), path: p.Path}] = p
+	}
+
+	return func(c *gin.Context) {
+		if c.FullPath() == "" {
+			// 未匹配路由的请求交给 gin 响应 404
+			c.Next()
+			return
+		}
+		policy, ok := table[policyKey{method: c.Request.Method, path: c.FullPath()}]
+		if !ok {
+			policy, ok = table[policyKey{method: "*", path: c.FullPath()}]
+		}
+
+		var status int
+		var reason string
+		switch {
+		case !ok:
+			if cfg.DenyByDefault {
+				status, reason = http.StatusForbidden, "no_policy"
+			}
+		case policy.Public:
+		default:
+			sub, found := cfg.Subject(c)
+			switch {
+			case !found:
+				status, reason = http.StatusUnauthorized, "no_subject"
+			case !cfg.RBAC.Allowed(sub, policy.Permissions...):
+				status, reason = http.StatusForbidden, "permission"
+			default:
+				for _, pred := range policy.Predicates {
+					if !pred.Check(c, sub) {
+						status, reason = http.StatusForbidden, "predicate:"+pred.Name
+						break
+					}
+				}
+			}
+		}
+
+		if status == 0 {
+			AddLogAttrs(c, slog.Group("authz", slog.String("decision", "allow")))
+			c.Next()
+			return
+		}
+
+		sub, _ := cfg.Subject(c)
+		attrs := []any{
+			slog.String("subject", sub.ID),
+			slog.Any("roles", sub.Roles),
+			slog.String("method", c.Request.Method),
+			slog.String("route", c.FullPath()),
+			slog.String("reason", reason),
+		}
+		if cfg.DryRun {
+			slog.WarnContext(c.Request.Context(), "授权策略将拒绝该请求（dry-run）", attrs...)
+			AddLogAttrs(c, slog.Group("authz", slog.String("decision", "dry_run_deny"), slog.String("reason", reason)))
+			c.Next()
+			return
+		}
+		slog.WarnContext(c.Request.Context(), "授权拒绝", attrs...)
+		AddLogAttrs(c, slog.Group("authz", slog.String("decision", "deny"), slog.String("reason", reason)))
+		if status == http.StatusUnauthorized {
+			AbortWithProblem(c, NewProblem(cerrors.Unauthorized))
+		} else {
+			AbortWithProblem(c, NewProblem(cerrors.Forbidden))
+		}
+	}
+}
+
+// AuthorizePolicyHandler 以 JSON 输出生效的授权策略，用于审计：
+// 每条路由策略需要的权限、自定义判断和满足权限的角色，以及所有角色展开继承后的权限。
+// 该接口暴露了权限模型，需要自行加上认证和授权
+func AuthorizePolicyHandler(cfg AuthorizeConfig) gin.HandlerFunc {
+	type routeView struct {
+		Method      string            `json:"method"`
+		Path        string            `json:"path"`
+		Public      bool              `json:"public,omitempty"`
+		Permissions []rbac.Permission `json:"permissions"`
+		Predicates  []string          `json:"predicates,omitempty"`
+		Roles       []string          `json:"roles"`
+	}
+	type roleView struct {
+		rbac.Role
+		Effective []rbac.Permission `json:"effective"`
+	}
+
+	return func(c *gin.Context) {
+		routes := make([]routeView, 0, len(cfg.Policies))
+		for _, p := range cfg.Policies {
+			view := routeView{
+				Method:      policyMethod(p.Method),
+				Path:        p.Path,
+				Public:      p.Public,
+				Permissions: p.Permissions,
+			}
+			for _, pred := range p.Predicates {
+				view.Predicates = append(view.Predicates, pred.Name)
+			}
+			if !p.Public {
+				view.Roles = cfg.RBAC.RolesAllowed(p.Permissions...)
+			}
+			routes = append(routes, view)
+		}
+		slices.SortFunc(
+			routes, func(a, b routeView) int {
+				if n := strings.Compare(a.Path, b.Path); n != 0 {
+					return n
+				}
+				return strings.Compare(a.Method, b.Method)
+			},
+		)
+
+		var roles []roleView
+		for _, role := range cfg.RBAC.Roles() {
+			roles = append(roles, roleView{Role: role, Effective: cfg.RBAC.Permissions(role.Name)})
+		}
+
+		c.JSON(
+			http.StatusOK, gin.H{
+				"denyByDefault": cfg.DenyByDefault,
+				"dryRun":        cfg.DryRun,
+				"routes":        routes,
+				"roles":         roles,
+			},
+		)
+	}
+}
+
+// SetSubject 由自定义的认证中间件设置请求主体
+func SetSubject(c *gin.Context, sub rbac.Subject) {
+	c.Set(subjectContextKey, sub)
+}
+
+// DefaultSubject 依次从 SetSubject、JWT 声明（sub、roles、tenant）和 API key（授权范围作为权限）获取请求主体
+func DefaultSubject(c *gin.Context) (rbac.Subject, bool) {
+	if val, ok := c.Get(subjectContextKey); ok {
+		if sub, ok := val.(rbac.Subject); ok {
+			return sub, true
+		}
+	}
+	if claims, ok := GetClaims(c); ok {
+		custom, _ := jwt.Custom[struct {
+			Roles  []string `json:"roles"`
+			Tenant string   `json:"tenant"`
+		}](claims)
+		sub := rbac.Subject{ID: claims.Subject, Roles: custom.Roles}
+		if custom.Tenant != "" {
+			sub.Attributes = map[string]any{"tenant": custom.Tenant}
+		}
+		return sub, true
+	}
+	if key, ok := GetAPIKey(c); ok {
+		sub := rbac.Subject{ID: "apikey:" + key.ID}
+		for _, scope := range key.Scopes {
+			sub.Permissions = append(sub.Permissions, rbac.Permission(scope))
+		}
+		return sub, true
+	}
+	return rbac.Subject{}, false
+}
+
+// policyMethod 统一方法的写法，空表示所有方法
+func policyMethod(method string) string {
+	if method == "" {
+		return "*"
+	}
+	return strings.ToUpper(method)
+}
@@ -0,0 +1,375 @@
+/**
+  @author: 35840
+  @date: 2026/10/19
+  @desc: GSlog 记录请求体和响应体：大小限制、Content-Type 过滤和字段脱敏
+**/
+
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huabingli/go-common/jsonutil"
+)
+
+// defaultBodyMaxSize 默认最多记录的 body 字节数
+const defaultBodyMaxSize = 4 << 10
+
+// truncatedMarker 截断标记
+const truncatedMarker = "...[truncated]"
+
+// unreadMarker 处理器没有读完请求体的标记
+const unreadMarker = "...[unread]"
+
+// redactedValue 脱敏后的值
+const redactedValue = "***"
+
+// bodyCaptureDisabledKey gin.Context 中关闭 body 记录的标记
+const bodyCaptureDisabledKey = "_go-common/bodyCaptureDisabled"
+
+// DefaultBodyContentTypes 默认允许记录的 Content-Type
+var DefaultBodyContentTypes = []string{
+	"application/json",
+	"application/x-www-form-urlencoded",
+	"text/plain",
+}
+
+// DefaultRedactFields 默认脱敏的字段
+var DefaultRedactFields = []string{
+	"password", "passwd", "secret", "token", "access_token", "refresh_token",
+	"authorization", "api_key", "apikey", "client_secret", "private_key",
+}
+
+// BodyCaptureConfig 请求体和响应体记录配置
+type BodyCaptureConfig struct {
+	Request  bool // 是否记录请求体
+	Response bool // 是否记录响应体
+	// MaxSize 最多记录的字节数，默认 4KB，超出部分截断并追加截断标记
+	MaxSize int
+	// ContentTypes 允许记录的 Content-Type，默认 DefaultBodyContentTypes；
+	// 支持 "text/*" 通配，允许 application/json 时同时允许 "+json" 后缀的类型；
+	// multipart 和 application/octet-stream 始终不记录
+	ContentTypes []string
+	// RedactFields JSON 和表单中需要脱敏的字段名，忽略大小写，默认 DefaultRedactFields
+	RedactFields []string
+	// Filter 按路由决定是否记录，返回 false 时不记录，为空时全部记录
+	Filter func(c *gin.Context) bool
+}
+
+// DisableBodyCapture 在路由上关闭 GSlog 的 body 记录，用于上传、下载等不适合记录的接口
+func DisableBodyCapture() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(bodyCaptureDisabledKey, true)
+		c.Next()
+	}
+}
+
+// bodyCapturer 预处理后的 body 记录配置
+type bodyCapturer struct {
+	cfg          BodyCaptureConfig
+	redactFields map[string]struct{}
+	formRedact   *regexp.Regexp
+}
+
+func newBodyCapturer(cfg BodyCaptureConfig) *bodyCapturer {
+	if cfg.MaxSize <= 0 {
+		cfg.MaxSize = defaultBodyMaxSize
+	}
+	if len(cfg.ContentTypes) == 0 {
+		cfg.ContentTypes = DefaultBodyContentTypes
+	}
+	if cfg.RedactFields == nil {
+		cfg.RedactFields = DefaultRedactFields
+	}
+
+	b := &bodyCapturer{cfg: cfg, redactFields: make(map[string]struct{}, len(cfg.RedactFields))}
+	if len(cfg.RedactFields) > 0 {
+		quoted := make([]string, 0, len(cfg.RedactFields))
+		for _, f := range cfg.RedactFields {
+			b.redactFields[strings.ToLower(f)] = struct{}{}
+			quoted = append(quoted, regexp.QuoteMeta(f))
+		}
+		names := strings.Join(quoted, "|")
+		// JSON 按 token 脱敏，表单按正则替换
+		b.formRedact = regexp.MustCompile(`(?i)((?:^|&)(?:` + names + `)=)[^&]*`)
+	}
+	return b
+}
+
+// enabled 判断当前请求是否需要记录 body
+func (b *bodyCapturer) enabled(c *gin.Context) bool {
+	return b.cfg.Filter == nil || b.cfg.Filter(c)
+}
+
+// allowed 判断 Content-Type 是否允许记录
+func (b *bodyCapturer) allowed(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	if strings.HasPrefix(mediaType, "multipart/") || mediaType == "application/octet-stream" {
+		return false
+	}
+	return matchMediaType(mediaType, b.cfg.ContentTypes)
+}
+
+// matchMediaType 判断媒体类型是否在列表中，支持 "text/*" 通配，列表包含 application/json 时同时匹配 "+json" 后缀的类型
+func matchMediaType(mediaType string, patterns []string) bool {
+	for _, pattern := range patterns {
+		switch {
+		case pattern == mediaType:
+			return true
+		case strings.HasSuffix(pattern, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(pattern, "*")):
+			return true
+		case pattern == "application/json" && strings.HasSuffix(mediaType, "+json"):
+			return true
+		}
+	}
+	return false
+}
+
+// captureRequest 在请求体上挂载 tee，处理器读取请求体时同步记录
+func (b *bodyCapturer) captureRequest(c *gin.Context) *teeReadCloser {
+	if !b.cfg.Request || c.Request.Body == nil || c.Request.Body == http.NoBody || !b.allowed(c.GetHeader("Content-Type")) {
+		return nil
+	}
+	buf := &limitedBuffer{limit: b.cfg.MaxSize}
+	tee := &teeReadCloser{Reader: io.TeeReader(c.Request.Body, buf), Closer: c.Request.Body, buf: buf}
+	c.Request.Body = tee
+	return tee
+}
+
+// renderRequest 只记录处理器实际读取的部分，没有读完时追加未读标记，不补读剩余的请求体：
+// 被拒绝的请求（401、413 等）不会因为慢客户端阻塞日志，也不会记录服务没有接受的数据
+func (b *bodyCapturer) renderRequest(r *http.Request, tee *teeReadCloser) string {
+	s := b.render(r.Header.Get("Content-Type"), tee.buf)
+	// json.Decoder 等读完一个值就停止，不一定读到 EOF，按 Content-Length 判断是否读完
+	complete := tee.eof || r.ContentLength >= 0 && int64(tee.buf.Len()) >= r.ContentLength
+	if !complete && !tee.buf.truncated {
+		s += unreadMarker
+	}
+	return s
+}
+
+// captureResponse 包装 ResponseWriter 记录响应体
+func (b *bodyCapturer) captureResponse(c *gin.Context) *captureWriter {
+	if !b.cfg.Response {
+		return nil
+	}
+	w := &captureWriter{ResponseWriter: c.Writer, capturer: b, body: &limitedBuffer{limit: b.cfg.MaxSize}}
+	c.Writer = w
+	return w
+}
+
+// render 将记录的 body 脱敏后转换为字符串
+func (b *bodyCapturer) render(contentType string, buf *limitedBuffer) string {
+	data := buf.Bytes()
+	if len(data) == 0 {
+		return ""
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+
+	var s string
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		s = b.redactJSON(data)
+	case mediaType == "application/x-www-form-urlencoded":
+		s = b.redactForm(data)
+	default:
+		s = string(data)
+	}
+	if buf.truncated {
+		s += truncatedMarker
+	}
+	return s
+}
+
+// redactJSON 逐个 token 扫描 JSON，只替换需要脱敏的字段的值，其余字节原样保留：
+// 不经过解析和重新序列化，大整数的精度、字段顺序和格式都不会改变，截断的 JSON 也适用
+func (b *bodyCapturer) redactJSON(data []byte) string {
+	if len(b.redactFields) == 0 {
+		return string(data)
+	}
+	var out strings.Builder
+	out.Grow(len(data))
+	for i := 0; i < len(data); {
+		if data[i] != '"' {
+			out.WriteByte(data[i])
+			i++
+			continue
+		}
+		end := scanJSONString(data, i)
+		out.Write(data[i:end])
+		key := data[i:end]
+		i = end
+		// 字符串后面（跳过空白）是冒号时为字段名
+		colon := skipJSONSpace(data, i)
+		if colon >= len(data) || data[colon] != ':' || !b.isRedactField(key) {
+			continue
+		}
+		value := skipJSONSpace(data, colon+1)
+		out.Write(data[i:value])
+		i = scanJSONValue(data, value)
+		if i > value {
+			out.WriteString(`"` + redactedValue + `"`)
+		}
+	}
+	return out.String()
+}
+
+// isRedactField 判断带引号的字段名是否需要脱敏
+func (b *bodyCapturer) isRedactField(quoted []byte) bool {
+	if len(quoted) < 2 || quoted[len(quoted)-1] != '"' {
+		return false
+	}
+	name := string(quoted[1 : len(quoted)-1])
+	if strings.Contains(name, `\`) {
+		if err := jsonutil.Unmarshal(quoted, &name); err != nil {
+			return false
+		}
+	}
+	_, ok := b.redactFields[strings.ToLower(name)]
+	return ok
+}
+
+// scanJSONString 返回从 i 开始的字符串结束引号之后的位置，字符串被截断时返回 len(data)
+func scanJSONString(data []byte, i int) int {
+	for j := i + 1; j < len(data); j++ {
+		switch data[j] {
+		case '\\':
+			j++
+		case '"':
+			return j + 1
+		}
+	}
+	return len(data)
+}
+
+// scanJSONValue 返回从 i 开始的值（字符串、对象、数组或字面量）结束之后的位置
+func scanJSONValue(data []byte, i int) int {
+	if i >= len(data) {
+		return i
+	}
+	switch data[i] {
+	case '"':
+		return scanJSONString(data, i)
+	case '{', '[':
+		depth := 0
+		for j := i; j < len(data); j++ {
+			switch data[j] {
+			case '"':
+				j = scanJSONString(data, j) - 1
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+				if depth == 0 {
+					return j + 1
+				}
+			}
+		}
+		return len(data)
+	default:
+		j := i
+		for j < len(data) && !strings.ContainsRune(",}] \t\r\n", rune(data[j])) {
+			j++
+		}
+		return j
+	}
+}
+
+// skipJSONSpace 跳过空白字符
+func skipJSONSpace(data []byte, i int) int {
+	for i < len(data) && (data[i] == ' ' || data[i] == '\t' || data[i] == '\r' || data[i] == '\n') {
+		i++
+	}
+	return i
+}
+
+// redactForm 脱敏表单字段，直接替换原文以保留字段顺序，截断的表单也适用
+func (b *bodyCapturer) redactForm(data []byte) string {
+	if len(b.redactFields) == 0 {
+		return string(data)
+	}
+	return b.formRedact.ReplaceAllString(string(data), "${1}"+redactedValue)
+}
+
+// limitedBuffer 只保留前 limit 个字节的缓冲区，写入永远不会失败
+type limitedBuffer struct {
+	bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+// Write 超出上限的部分直接丢弃并标记截断
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	remaining := b.limit - b.Len()
+	if len(p) > remaining {
+		b.truncated = true
+		if remaining > 0 {
+			b.Buffer.Write(p[:remaining])
+		}
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
+
+// full 是否已经达到上限
+func (b *limitedBuffer) full() bool {
+	return b.truncated || b.Len() >= b.limit
+}
+
+// teeReadCloser 读取时同步写入缓冲区的请求体
+type teeReadCloser struct {
+	io.Reader
+	io.Closer
+	buf *limitedBuffer
+	eof bool // 处理器是否读到了请求体末尾
+}
+
+func (t *teeReadCloser) Read(p []byte) (int, error) {
+	n, err := t.Reader.Read(p)
+	if err == io.EOF {
+		t.eof = true
+	}
+	return n, err
+}
+
+// captureWriter 记录响应体的 ResponseWriter
+type captureWriter struct {
+	gin.ResponseWriter
+	capturer *bodyCapturer
+	body     *limitedBuffer
+	decided  bool
+	allowed  bool
+}
+
+// capture 第一次写入时根据 Content-Type 和 Content-Encoding 决定是否记录，
+// 已经压缩（如内层的 Compress）的响应体无法脱敏，不记录
+func (w *captureWriter) capture(p []byte) {
+	if !w.decided {
+		w.decided = true
+		encoding := strings.TrimSpace(w.Header().Get("Content-Encoding"))
+		w.allowed = (encoding == "" || strings.EqualFold(encoding, "identity")) &&
+			w.capturer.allowed(w.Header().Get("Content-Type"))
+	}
+	if w.allowed {
+		_, _ = w.body.Write(p)
+	}
+}
+
+func (w *captureWriter) Write(p []byte) (int, error) {
+	w.capture(p)
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *captureWriter) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
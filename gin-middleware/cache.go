@@ -0,0 +1,348 @@
+/**
+  @author: 35840
+  @date: 2026/10/19
+  @desc: 响应缓存：TTL、stale-while-revalidate、按查询参数和请求头区分、singleflight 合并并发未命中、按标签失效
+**/
+
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huabingli/go-common"
+	"github.com/huabingli/go-common/httpcache"
+	"golang.org/x/sync/singleflight"
+)
+
+// cacheTagsKey gin.Context 中处理器追加的缓存标签
+const cacheTagsKey = "_go-common/cacheTags"
+
+// DefaultCacheName Cache-Status 响应头中的缓存名
+const DefaultCacheName = "go-common"
+
+// CacheConfig 响应缓存配置
+type CacheConfig struct {
+	// Store 缓存存储，默认 httpcache.NewMemoryStore()；需要按标签失效时应显式创建并与 InvalidateCache 共用
+	Store httpcache.Store
+	// TTL 响应保持新鲜的时间，默认 10 秒
+	TTL time.Duration
+	// StaleWhileRevalidate 过期后仍可使用旧响应的时间：第一个请求重新执行处理器刷新缓存，期间其他请求直接返回旧响应
+	StaleWhileRevalidate time.Duration
+	// QueryParams 参与缓存 key 的查询参数，为空时使用全部查询参数（按参数名排序，与参数顺序无关）
+	QueryParams []string
+	// VaryHeaders 参与缓存 key 的请求头，同时写入响应的 Vary；
+	// 响应的 Vary 包含其他请求头时不缓存，如缓存 Compress 压缩后的响应需要包含 Accept-Encoding
+	VaryHeaders []string
+	// Scope 区分调用方，用于按用户缓存的接口，如 KeyBySubject()；
+	// 为空时带 Authorization、Cookie 或 API key 的请求，以及 DefaultSubject 能取到认证主体的请求都不使用缓存
+	Scope KeyFunc
+	// Tags 条目的标签，处理器也可以通过 AddCacheTags 追加
+	Tags func(c *gin.Context) []string
+	// MaxBodySize 可以缓存的响应体最大字节数，默认 1MB
+	MaxBodySize int
+	// Name Cache-Status 响应头中的缓存名，默认 DefaultCacheName
+	Name string
+	// Skip 返回 true 时不使用缓存
+	Skip func(c *gin.Context) bool
+}
+
+// Cache 使用内存存储创建响应缓存中间件
+func Cache(ttl time.Duration) gin.HandlerFunc {
+	return CacheWithConfig(CacheConfig{TTL: ttl})
+}
+
+// CacheWithConfig 创建响应缓存中间件，只缓存 GET 的 200 响应，HEAD 请求使用 GET 缓存的响应，未命中时直接执行处理器。
+// 需要注册在认证中间件（JWT、APIKey）之后，否则无法识别已认证的请求；
+// 响应带有 Set-Cookie、Cache-Control 包含 no-store、no-cache、private，或 Vary 包含 VaryHeaders 以外的请求头时不缓存；
+// 响应头 X-Cache 为 HIT、STALE、MISS 或 BYPASS，同时按 RFC 9211 输出 Cache-Status
+func CacheWithConfig(cfg CacheConfig) gin.HandlerFunc {
+	if cfg.Store == nil {
+		cfg.Store = httpcache.NewMemoryStore()
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = 10 * time.Second
+	}
+	if cfg.MaxBodySize <= 0 {
+		cfg.MaxBodySize = 1 << 20
+	}
+	if cfg.Name == "" {
+		cfg.Name = DefaultCacheName
+	}
+	cfg.VaryHeaders = slices.Clone(cfg.VaryHeaders)
+	for i, name := range cfg.VaryHeaders {
+		cfg.VaryHeaders[i] = http.CanonicalHeaderKey(name)
+	}
+	rc := &responseCache{cfg: cfg}
+
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead ||
+			cfg.Scope == nil && authenticatedRequest(c) ||
+			cfg.Skip != nil && cfg.Skip(c) {
+			c.Next()
+			return
+		}
+
+		key := rc.key(c)
+		entry, ok, err := cfg.Store.Get(c.Request.Context(), key)
+		if err != nil {
+			slog.WarnContext(c.Request.Context(), "读取响应缓存失败", slog.Any("err", err))
+			ok = false
+		}
+		now := time.Now()
+		if ok && entry.Fresh(now) {
+			rc.serve(c, entry, "HIT", "hit; ttl="+strconv.Itoa(int(entry.FreshUntil.Sub(now).Seconds())))
+			return
+		}
+		if c.Request.Method == http.MethodHead {
+			// HEAD 的处理器可能不写响应体，只使用 GET 保存的响应，不保存 HEAD 的响应
+			if ok {
+				rc.serve(c, entry, "STALE", "hit; ttl="+strconv.Itoa(int(entry.FreshUntil.Sub(now).Seconds())))
+				return
+			}
+			rc.bypass(c)
+			return
+		}
+		if ok {
+			// 只让一个请求刷新，其他请求返回旧响应
+			if _, loaded := rc.revalidating.LoadOrStore(key, struct{}{}); loaded {
+				rc.serve(c, entry, "STALE", "hit; ttl="+strconv.Itoa(int(entry.FreshUntil.Sub(now).Seconds())))
+				return
+			}
+			defer rc.revalidating.Delete(key)
+			rc.fetch(c, key, "fwd=stale")
+			return
+		}
+
+		leader := false
+		v, _, _ := rc.group.Do(
+			key, func() (any, error) {
+				leader = true
+				return rc.fetch(c, key, "fwd=miss"), nil
+			},
+		)
+		if leader {
+			return
+		}
+		if shared, _ := v.(*httpcache.Entry); shared != nil {
+			rc.serve(c, shared, "HIT", "fwd=miss; collapsed")
+			return
+		}
+		// 第一个请求的响应不能缓存，各自执行处理器
+		rc.bypass(c)
+	}
+}
+
+// authenticatedRequest 判断请求是否携带认证信息，这类请求的响应通常因调用方而不同
+func authenticatedRequest(c *gin.Context) bool {
+	for _, name := range []string{"Authorization", "Cookie", DefaultAPIKeyHeader} {
+		if c.GetHeader(name) != "" {
+			return true
+		}
+	}
+	_, ok := DefaultSubject(c)
+	return ok
+}
+
+// AddCacheTags 给当前请求的缓存条目追加标签，在处理器中调用
+func AddCacheTags(c *gin.Context, tags ...string) {
+	existing, _ := c.Get(cacheTagsKey)
+	list, _ := existing.([]string)
+	c.Set(cacheTagsKey, append(list, tags...))
+}
+
+// InvalidateCache 写请求成功（状态码小于 400）后删除带有任一标签的缓存条目，tags 返回需要失效的标签
+func InvalidateCache(store httpcache.Store, tags func(c *gin.Context) []string) gin.HandlerFunc {
+	if store == nil || tags == nil {
+		panic("middleware: InvalidateCache 的 store 和 tags 不能为空")
+	}
+	return func(c *gin.Context) {
+		c.Next()
+
+		if c.Writer.Status() >= http.StatusBadRequest || len(c.Errors) > 0 {
+			return
+		}
+		list := tags(c)
+		if len(list) == 0 {
+			return
+		}
+		ctx := c.Request.Context()
+		removed, err := store.InvalidateTags(context.WithoutCancel(ctx), list...)
+		if err != nil {
+			slog.ErrorContext(ctx, "按标签删除响应缓存失败", slog.Any("tags", list), slog.Any("err", err))
+			return
+		}
+		AddLogAttrs(c, slog.Group("cache", slog.Any("invalidated", list), slog.Int("removed", removed)))
+	}
+}
+
+// responseCache 缓存中间件的状态
+type responseCache struct {
+	cfg          CacheConfig
+	group        singleflight.Group
+	revalidating sync.Map // 正在刷新的 stale 条目的 key
+}
+
+// key 按域名、路径、查询参数、请求头和调用方生成缓存 key
+func (rc *responseCache) key(c *gin.Context) string {
+	query := c.Request.URL.Query()
+	if len(rc.cfg.QueryParams) > 0 {
+		selected := make(url.Values, len(rc.cfg.QueryParams))
+		for _, name := range rc.cfg.QueryParams {
+			if values, ok := query[name]; ok {
+				selected[name] = values
+			}
+		}
+		query = selected
+	}
+
+	var b strings.Builder
+	// 同一个服务可能通过多个域名访问（多租户子域名、虚拟主机），不同域名的响应不能互相复用
+	b.WriteString(strings.ToLower(c.Request.Host))
+	b.WriteString(c.Request.URL.Path)
+	b.WriteString("?")
+	b.WriteString(query.Encode())
+	for _, name := range rc.cfg.VaryHeaders {
+		b.WriteString("\n" + name + ":" + strings.Join(c.Request.Header.Values(name), ","))
+	}
+	if rc.cfg.Scope != nil {
+		b.WriteString("\nscope:" + rc.cfg.Scope(c))
+	}
+	return common.SHA256V([]byte(b.String()))
+}
+
+// serve 输出缓存的响应
+func (rc *responseCache) serve(c *gin.Context, entry *httpcache.Entry, result, status string) {
+	h := c.Writer.Header()
+	for k, v := range entry.Header {
+		h[k] = slices.Clone(v)
+	}
+	h.Set("X-Cache", result)
+	h.Set("Cache-Status", rc.cfg.Name+"; "+status)
+	h.Set("Age", strconv.Itoa(int(time.Since(entry.CreatedAt).Seconds())))
+	AddLogAttrs(c, slog.Group("cache", slog.String("result", strings.ToLower(result))))
+
+	c.Abort()
+	c.Status(entry.StatusCode)
+	_, _ = c.Writer.Write(entry.Body)
+	c.Writer.WriteHeaderNow()
+}
+
+// bypass 不使用缓存，直接执行处理器
+func (rc *responseCache) bypass(c *gin.Context) {
+	c.Header("X-Cache", "BYPASS")
+	c.Header("Cache-Status", rc.cfg.Name+"; fwd=bypass")
+	AddLogAttrs(c, slog.Group("cache", slog.String("result", "bypass")))
+	c.Next()
+}
+
+// fetch 执行处理器并记录响应，可以缓存时保存并返回条目，否则返回 nil
+func (rc *responseCache) fetch(c *gin.Context, key, status string) *httpcache.Entry {
+	h := c.Writer.Header()
+	h.Set("X-Cache", "MISS")
+	h.Set("Cache-Status", rc.cfg.Name+"; "+status)
+	for _, name := range rc.cfg.VaryHeaders {
+		h.Add("Vary", name)
+	}
+
+	w := &recordingWriter{ResponseWriter: c.Writer, body: &limitedBuffer{limit: rc.cfg.MaxBodySize}}
+	c.Writer = w
+	defer func() {
+		c.Writer = w.ResponseWriter
+	}()
+
+	c.Next()
+
+	if !rc.cacheable(c, w) {
+		AddLogAttrs(c, slog.Group("cache", slog.String("result", "miss")))
+		return nil
+	}
+	now := time.Now()
+	entry := &httpcache.Entry{
+		StatusCode: w.Status(),
+		Header:     cacheableHeader(w.Header()),
+		Body:       slices.Clone(w.body.Bytes()),
+		Tags:       rc.tags(c),
+		CreatedAt:  now,
+		FreshUntil: now.Add(rc.cfg.TTL),
+	}
+	ctx := c.Request.Context()
+	if err := rc.cfg.Store.Set(context.WithoutCancel(ctx), key, entry, rc.cfg.TTL+rc.cfg.StaleWhileRevalidate); err != nil {
+		slog.WarnContext(ctx, "保存响应缓存失败", slog.Any("err", err))
+		return nil
+	}
+	AddLogAttrs(c, slog.Group("cache", slog.String("result", "stored")))
+	return entry
+}
+
+// cacheable 判断响应是否可以缓存
+func (rc *responseCache) cacheable(c *gin.Context, w *recordingWriter) bool {
+	if w.Status() != http.StatusOK || w.body.truncated || len(c.Errors) > 0 {
+		return false
+	}
+	h := w.Header()
+	if len(h.Values("Set-Cookie")) > 0 {
+		return false
+	}
+	cacheControl := strings.ToLower(strings.Join(h.Values("Cache-Control"), ","))
+	if strings.Contains(cacheControl, "no-store") ||
+		strings.Contains(cacheControl, "no-cache") ||
+		strings.Contains(cacheControl, "private") {
+		return false
+	}
+	return rc.varyCovered(h)
+}
+
+// varyCovered 判断响应的 Vary 是否都在 VaryHeaders 中：缓存 key 只包含 VaryHeaders，
+// 其他的 Vary（如 Compress 追加的 Accept-Encoding）会让不同请求头的客户端拿到同一份响应；Vary: * 始终不缓存
+func (rc *responseCache) varyCovered(h http.Header) bool {
+	for _, value := range h.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			if name == "*" || !slices.Contains(rc.cfg.VaryHeaders, http.CanonicalHeaderKey(name)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// tags 合并配置和处理器追加的标签
+func (rc *responseCache) tags(c *gin.Context) []string {
+	var tags []string
+	if rc.cfg.Tags != nil {
+		tags = rc.cfg.Tags(c)
+	}
+	if extra, ok := c.Get(cacheTagsKey); ok {
+		list, _ := extra.([]string)
+		tags = append(slices.Clone(tags), list...)
+	}
+	slices.Sort(tags)
+	return slices.Compact(tags)
+}
+
+// cacheableHeader 复制需要缓存的响应头，去掉与当前请求相关的响应头
+func cacheableHeader(h http.Header) http.Header {
+	result := make(http.Header, len(h))
+	for k, v := range h {
+		switch k {
+		case "X-Cache", "Cache-Status", "Age":
+			continue
+		}
+		if !isReplayExcludedHeader(k) {
+			result[k] = slices.Clone(v)
+		}
+	}
+	return result
+}
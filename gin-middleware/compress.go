@@ -0,0 +1,519 @@
+/**
+  @author: 35840
+  @date: 2026/10/19
+  @desc: 响应压缩（br、zstd、gzip、deflate）和请求体解压
+**/
+
+package middleware
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+	"github.com/huabingli/go-common/errors"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zlib"
+	"github.com/klauspost/compress/zstd"
+)
+
+// 支持的内容编码，HTTP 中的 deflate 是 zlib 格式
+const (
+	EncodingBrotli  = "br"
+	EncodingZstd    = "zstd"
+	EncodingGzip    = "gzip"
+	EncodingDeflate = "deflate"
+)
+
+// eventStreamType SSE 的 Content-Type，始终不压缩
+const eventStreamType = "text/event-stream"
+
+// DefaultCompressEncodings 默认支持的响应编码，按服务端优先级排列
+var DefaultCompressEncodings = []string{EncodingBrotli, EncodingZstd, EncodingGzip, EncodingDeflate}
+
+// DefaultCompressContentTypes 默认压缩的 Content-Type
+var DefaultCompressContentTypes = []string{
+	"text/*",
+	"application/json",
+	"application/javascript",
+	"application/xml",
+	"application/x-ndjson",
+	"image/svg+xml",
+}
+
+// CompressConfig 响应压缩配置
+type CompressConfig struct {
+	// Encodings 支持的编码，按服务端优先级排列，客户端 q 值相同时选择靠前的编码，默认 DefaultCompressEncodings
+	Encodings []string
+	// Levels 各编码的压缩级别，未配置的编码使用默认级别（br 为 4，其余为各自的默认级别）
+	Levels map[string]int
+	// MinSize 小于该字节数的响应不压缩，默认 1KB；处理器调用 Flush 时不再等待，直接开始压缩
+	MinSize int
+	// ContentTypes 压缩的 Content-Type，规则与 BodyCaptureConfig.ContentTypes 相同，默认 DefaultCompressContentTypes；
+	// text/event-stream 始终不压缩
+	ContentTypes []string
+	// Skip 返回 true 时不压缩
+	Skip func(c *gin.Context) bool
+}
+
+// Compress 使用默认配置创建响应压缩中间件
+func Compress() gin.HandlerFunc {
+	return CompressWithConfig(CompressConfig{})
+}
+
+// CompressWithConfig 创建响应压缩中间件，按 Accept-Encoding 协商编码。
+// 响应体先缓冲到 MinSize 再决定是否压缩，压缩时删除 Content-Length、强 ETag 改为弱 ETag，并追加 Vary: Accept-Encoding；
+// 已经设置了 Content-Encoding、Cache-Control: no-transform、HEAD 请求、Range 请求和 204/206/304 响应不压缩
+func CompressWithConfig(cfg CompressConfig) gin.HandlerFunc {
+	if len(cfg.Encodings) == 0 {
+		cfg.Encodings = DefaultCompressEncodings
+	}
+	if cfg.MinSize <= 0 {
+		cfg.MinSize = 1 << 10
+	}
+	if len(cfg.ContentTypes) == 0 {
+		cfg.ContentTypes = DefaultCompressContentTypes
+	}
+	pools := make(map[string]*encoderPool, len(cfg.Encodings))
+	for _, encoding := range cfg.Encodings {
+		level, ok := cfg.Levels[encoding]
+		if !ok {
+			level = defaultCompressLevel(encoding)
+		}
+		pool, err := newEncoderPool(encoding, level)
+		if err != nil {
+			panic("middleware: " + err.Error())
+		}
+		pools[encoding] = pool
+	}
+
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodHead || c.GetHeader("Range") != "" || c.GetHeader("Upgrade") != "" ||
+			cfg.Skip != nil && cfg.Skip(c) {
+			c.Next()
+			return
+		}
+
+		encoding := negotiateEncoding(c.GetHeader("Accept-Encoding"), cfg.Encodings)
+		w := &compressWriter{
+			ResponseWriter: c.Writer,
+			cfg:            &cfg,
+			encoding:       encoding,
+			pool:           pools[encoding],
+		}
+		c.Writer = w
+		defer func() {
+			c.Writer = w.ResponseWriter
+			w.release()
+		}()
+
+		c.Next()
+
+		w.finish()
+	}
+}
+
+// defaultCompressLevel 各编码的默认压缩级别，br 默认的 6 级对动态内容偏慢，使用 4 级
+func defaultCompressLevel(encoding string) int {
+	switch encoding {
+	case EncodingBrotli:
+		return 4
+	case EncodingZstd:
+		return 3
+	case EncodingGzip:
+		return gzip.DefaultCompression
+	default:
+		return zlib.DefaultCompression
+	}
+}
+
+// negotiateEncoding 按 Accept-Encoding 的 q 值选择编码，q 值相同时按 supported 的顺序，没有可用的编码时返回空字符串
+func negotiateEncoding(header string, supported []string) string {
+	if header == "" {
+		return ""
+	}
+	weights := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "x-gzip" {
+			name = EncodingGzip
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if name != "" {
+			weights[name] = q
+		}
+	}
+
+	best, bestQ := "", 0.0
+	for _, encoding := range supported {
+		q, ok := weights[encoding]
+		if !ok {
+			q, ok = weights["*"]
+		}
+		if ok && q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
+
+// encoder 可以复用的压缩器
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// encoderPool 按编码和压缩级别复用压缩器
+type encoderPool struct {
+	pool sync.Pool
+}
+
+// newEncoderPool 创建压缩器池，先创建一个压缩器校验编码和压缩级别
+func newEncoderPool(encoding string, level int) (*encoderPool, error) {
+	enc, err := newEncoder(encoding, level)
+	if err != nil {
+		return nil, err
+	}
+	p := &encoderPool{}
+	p.pool.New = func() any {
+		enc, _ := newEncoder(encoding, level)
+		return enc
+	}
+	p.pool.Put(enc)
+	return p, nil
+}
+
+// get 取出压缩器并输出到 w
+func (p *encoderPool) get(w io.Writer) encoder {
+	enc := p.pool.Get().(encoder)
+	enc.Reset(w)
+	return enc
+}
+
+// put 归还压缩器，归还前断开与响应的关联
+func (p *encoderPool) put(enc encoder) {
+	enc.Reset(io.Discard)
+	p.pool.Put(enc)
+}
+
+// newEncoder 创建指定编码和压缩级别的压缩器
+func newEncoder(encoding string, level int) (encoder, error) {
+	switch encoding {
+	case EncodingBrotli:
+		if level < brotli.BestSpeed || level > brotli.BestCompression {
+			return nil, fmt.Errorf("无效的 br 压缩级别 %d", level)
+		}
+		return brotli.NewWriterLevel(io.Discard, level), nil
+	case EncodingZstd:
+		enc, err := zstd.NewWriter(
+			nil, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)), zstd.WithEncoderConcurrency(1),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("创建 zstd 压缩器失败: %w", err)
+		}
+		return enc, nil
+	case EncodingGzip:
+		enc, err := gzip.NewWriterLevel(io.Discard, level)
+		if err != nil {
+			return nil, fmt.Errorf("无效的 gzip 压缩级别 %d", level)
+		}
+		return enc, nil
+	case EncodingDeflate:
+		enc, err := zlib.NewWriterLevel(io.Discard, level)
+		if err != nil {
+			return nil, fmt.Errorf("无效的 deflate 压缩级别 %d", level)
+		}
+		return enc, nil
+	default:
+		return nil, fmt.Errorf("不支持的压缩编码 %q", encoding)
+	}
+}
+
+// compressWriter 缓冲响应体直到可以决定是否压缩，决定之后直接写出或经压缩器写出
+type compressWriter struct {
+	gin.ResponseWriter
+	cfg      *CompressConfig
+	encoding string // 协商出的编码，为空表示客户端不接受压缩
+	pool     *encoderPool
+	buf      []byte
+	decided  bool
+	streamed bool    // 处理器调用过 Flush
+	enc      encoder // 不为空时响应体经压缩器写出
+}
+
+// decide 根据状态码、响应头和已缓冲的大小决定是否压缩，只执行一次
+func (w *compressWriter) decide() {
+	w.decided = true
+	h := w.Header()
+	if !w.compressible(h) {
+		return
+	}
+	// 可以压缩的响应不论这次是否压缩都输出 Vary，避免共享缓存把未压缩的响应返回给所有客户端，
+	// 或把小于 MinSize 的未压缩版本当作唯一的版本保存
+	h.Add("Vary", "Accept-Encoding")
+	if w.encoding == "" || len(w.buf) < w.cfg.MinSize && !w.streamed {
+		return
+	}
+	h.Set("Content-Encoding", w.encoding)
+	h.Del("Content-Length")
+	if etag := h.Get("ETag"); strings.HasPrefix(etag, `"`) {
+		h.Set("ETag", "W/"+etag)
+	}
+	w.enc = w.pool.get(w.ResponseWriter)
+}
+
+// compressible 判断响应是否可以压缩，没有 Content-Type 时按已缓冲的内容识别并补上
+func (w *compressWriter) compressible(h http.Header) bool {
+	switch status := w.Status(); {
+	case status < http.StatusOK, status == http.StatusNoContent,
+		status == http.StatusPartialContent, status == http.StatusNotModified:
+		return false
+	}
+	if h.Get("Content-Encoding") != "" || strings.Contains(strings.ToLower(h.Get("Cache-Control")), "no-transform") {
+		return false
+	}
+	contentType := h.Get("Content-Type")
+	if contentType == "" {
+		if len(w.buf) == 0 {
+			return false
+		}
+		contentType = http.DetectContentType(w.buf)
+		h.Set("Content-Type", contentType)
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType == eventStreamType {
+		return false
+	}
+	return matchMediaType(mediaType, w.cfg.ContentTypes)
+}
+
+// isEventStream 判断响应是否为 SSE
+func (w *compressWriter) isEventStream() bool {
+	mediaType, _, _ := mime.ParseMediaType(w.Header().Get("Content-Type"))
+	return mediaType == eventStreamType
+}
+
+// flushBuffer 写出已缓冲的响应体
+func (w *compressWriter) flushBuffer() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	buf := w.buf
+	w.buf = nil
+	if w.enc != nil {
+		_, err := w.enc.Write(buf)
+		return err
+	}
+	_, err := w.ResponseWriter.Write(buf)
+	return err
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	if !w.decided {
+		if !w.isEventStream() {
+			w.buf = append(w.buf, p...)
+			if len(w.buf) < w.cfg.MinSize {
+				return len(p), nil
+			}
+			w.decide()
+			if err := w.flushBuffer(); err != nil {
+				return 0, err
+			}
+			return len(p), nil
+		}
+		w.decide()
+	}
+	if w.enc != nil {
+		return w.enc.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// WriteHeaderNow 决定是否压缩之前不写出响应头，否则无法再设置 Content-Encoding
+func (w *compressWriter) WriteHeaderNow() {
+	if w.decided {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+// Written 已经缓冲了响应体也视为已写出，避免后续的中间件再次写入响应
+func (w *compressWriter) Written() bool {
+	return len(w.buf) > 0 || w.ResponseWriter.Written()
+}
+
+// Flush 流式响应不再等待 MinSize，立即决定是否压缩并写出
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		w.streamed = true
+		w.decide()
+	}
+	_ = w.flushBuffer()
+	if w.enc != nil {
+		_ = w.enc.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// finish 处理器结束后写出剩余的响应体并结束压缩流
+func (w *compressWriter) finish() {
+	if !w.decided {
+		w.decide()
+	}
+	_ = w.flushBuffer()
+	if w.enc != nil {
+		_ = w.enc.Close()
+		w.pool.put(w.enc)
+		w.enc = nil
+	}
+}
+
+// release 处理器 panic 时归还压缩器
+func (w *compressWriter) release() {
+	if w.enc != nil {
+		w.pool.put(w.enc)
+		w.enc = nil
+	}
+}
+
+// DecompressConfig 请求体解压配置
+type DecompressConfig struct {
+	// MaxSize 解压后请求体的最大字节数，超出时读取请求体返回 *http.MaxBytesError，ErrorHandler 会转换为 413，默认 10MB
+	MaxSize int64
+	// Encodings 支持的请求编码，默认 br、zstd、gzip、deflate
+	Encodings []string
+}
+
+// Decompress 使用默认配置创建请求体解压中间件
+func Decompress() gin.HandlerFunc {
+	return DecompressWithConfig(DecompressConfig{})
+}
+
+// DecompressWithConfig 创建请求体解压中间件，按 Content-Encoding 解压请求体，限制解压后的大小防止压缩炸弹；
+// 不支持的编码响应 415 并通过 Accept-Encoding 告知支持的编码
+func DecompressWithConfig(cfg DecompressConfig) gin.HandlerFunc {
+	if cfg.MaxSize <= 0 {
+		cfg.MaxSize = 10 << 20
+	}
+	if len(cfg.Encodings) == 0 {
+		cfg.Encodings = DefaultCompressEncodings
+	}
+	for _, encoding := range cfg.Encodings {
+		if !slices.Contains(DefaultCompressEncodings, encoding) {
+			panic(fmt.Sprintf("middleware: 不支持的解压编码 %q", encoding))
+		}
+	}
+	zstdPool := &sync.Pool{
+		New: func() any {
+			dec, _ := zstd.NewReader(
+				nil, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxMemory(uint64(cfg.MaxSize)),
+			)
+			return dec
+		},
+	}
+
+	return func(c *gin.Context) {
+		encoding := strings.ToLower(strings.TrimSpace(c.GetHeader("Content-Encoding")))
+		if encoding == "x-gzip" {
+			encoding = EncodingGzip
+		}
+		if encoding == "" || encoding == "identity" || c.Request.Body == nil || c.Request.Body == http.NoBody {
+			c.Next()
+			return
+		}
+		if !slices.Contains(cfg.Encodings, encoding) {
+			c.Header("Accept-Encoding", strings.Join(cfg.Encodings, ", "))
+			p := NewProblem(errors.UnsupportedMedia)
+			p.Detail = "不支持的 Content-Encoding: " + encoding
+			AbortWithProblem(c, p)
+			return
+		}
+
+		body, err := newDecoder(encoding, c.Request.Body, zstdPool)
+		if err != nil {
+			_ = c.Error(errors.BadRequest.Wrap(err, "请求体解压失败"))
+			p := NewProblem(errors.BadRequest)
+			p.Detail = "请求体解压失败"
+			AbortWithProblem(c, p)
+			return
+		}
+		defer body.Close()
+
+		c.Request.Body = http.MaxBytesReader(c.Writer, body, cfg.MaxSize)
+		c.Request.ContentLength = -1
+		c.Request.Header.Del("Content-Encoding")
+		c.Request.Header.Del("Content-Length")
+		c.Next()
+	}
+}
+
+// decoder 解压后的请求体，关闭时同时关闭原始请求体，可以重复关闭
+type decoder struct {
+	io.Reader
+	source  io.Closer
+	release func()
+	closed  bool
+}
+
+func (d *decoder) Close() error {
+	if d.closed {
+		return nil
+	}
+	d.closed = true
+	if d.release != nil {
+		d.release()
+	}
+	return d.source.Close()
+}
+
+// newDecoder 创建指定编码的解压器，gzip 和 deflate 会先读取并校验头部
+func newDecoder(encoding string, body io.ReadCloser, zstdPool *sync.Pool) (*decoder, error) {
+	d := &decoder{source: body}
+	switch encoding {
+	case EncodingBrotli:
+		d.Reader = brotli.NewReader(body)
+	case EncodingZstd:
+		dec := zstdPool.Get().(*zstd.Decoder)
+		if err := dec.Reset(body); err != nil {
+			zstdPool.Put(dec)
+			return nil, err
+		}
+		d.Reader = dec
+		d.release = func() {
+			_ = dec.Reset(nil)
+			zstdPool.Put(dec)
+		}
+	case EncodingGzip:
+		dec, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, err
+		}
+		d.Reader = dec
+	case EncodingDeflate:
+		dec, err := zlib.NewReader(body)
+		if err != nil {
+			return nil, err
+		}
+		d.Reader = dec
+	}
+	return d, nil
+}
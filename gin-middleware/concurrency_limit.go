@@ -0,0 +1,348 @@
+/**
+  @author: 35840
+  @date: 2026/10/19
+  @desc: 并发数限制和过载保护：排队、超时、自适应上限和优先级
+**/
+
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huabingli/go-common"
+	"github.com/huabingli/go-common/errors"
+)
+
+// Priority 请求优先级，上限已满时高优先级的请求先出队
+type Priority int
+
+const (
+	// PriorityLow 低优先级，上限已满时直接拒绝，不排队
+	PriorityLow Priority = iota
+	// PriorityNormal 默认优先级
+	PriorityNormal
+	// PriorityHigh 高优先级，排在普通请求之前
+	PriorityHigh
+	// PriorityCritical 关键请求（健康检查、管理接口），不受上限限制，永远不会被拒绝
+	PriorityCritical
+)
+
+// PriorityFunc 返回请求的优先级
+type PriorityFunc func(c *gin.Context) Priority
+
+// PriorityByPath 路径匹配 critical 的为 PriorityCritical，其余为 PriorityNormal，
+// 路径规则与 LogRule.Paths 相同，支持 path.Match 通配符和 "/**" 前缀匹配
+func PriorityByPath(critical ...string) PriorityFunc {
+	return func(c *gin.Context) Priority {
+		for _, p := range critical {
+			if matchPath(p, c.Request.URL.Path) {
+				return PriorityCritical
+			}
+		}
+		return PriorityNormal
+	}
+}
+
+// AdaptiveLimit 根据请求的耗时和结果调整并发上限，在限制器的锁内调用，有状态的实现不能在多个中间件之间共用
+type AdaptiveLimit interface {
+	// Update 请求完成后调用，返回新的上限
+	Update(limit, inFlight int, latency time.Duration, failed bool) int
+}
+
+// ConcurrencyLimitConfig 并发限制中间件配置，
+// 每次调用 ConcurrencyLimitWithConfig 创建独立的限制器，注册到 engine 上为全局上限，注册到路由组上为该组的上限
+type ConcurrencyLimitConfig struct {
+	// MaxInFlight 同时处理的请求数上限，使用 Adaptive 时为初始上限，默认 100
+	MaxInFlight int
+	// QueueSize 上限已满时最多排队等待的请求数，为 0 时不排队直接拒绝
+	QueueSize int
+	// QueueTimeout 排队的最长等待时间，默认 100ms
+	QueueTimeout time.Duration
+	// Adaptive 非空时按请求耗时自适应调整上限，见 NewAIMDLimit、NewGradientLimit
+	Adaptive AdaptiveLimit
+	// Priority 请求优先级，默认都为 PriorityNormal
+	Priority PriorityFunc
+	// RetryAfter 被拒绝时 Retry-After 响应头的值，默认 1 秒
+	RetryAfter time.Duration
+	// ShedHandler 自定义被拒绝时的响应，需要调用 c.Abort，默认输出 503 problem+json
+	ShedHandler func(c *gin.Context)
+}
+
+// ConcurrencyLimit 限制同时处理的请求数，超出时直接拒绝
+func ConcurrencyLimit(maxInFlight int) gin.HandlerFunc {
+	return ConcurrencyLimitWithConfig(ConcurrencyLimitConfig{MaxInFlight: maxInFlight})
+}
+
+// ConcurrencyLimitWithConfig 按配置创建并发限制中间件，决策结果以 "concurrency" 分组附加到 GSlog 的访问日志
+func ConcurrencyLimitWithConfig(cfg ConcurrencyLimitConfig) gin.HandlerFunc {
+	if cfg.MaxInFlight <= 0 {
+		cfg.MaxInFlight = 100
+	}
+	if cfg.QueueTimeout <= 0 {
+		cfg.QueueTimeout = 100 * time.Millisecond
+	}
+	if cfg.Priority == nil {
+		cfg.Priority = func(*gin.Context) Priority { return PriorityNormal }
+	}
+	if cfg.RetryAfter <= 0 {
+		cfg.RetryAfter = time.Second
+	}
+	if cfg.ShedHandler == nil {
+		cfg.ShedHandler = func(c *gin.Context) {
+			AbortWithProblem(c, NewProblem(errors.ServiceUnavailable))
+		}
+	}
+	limiter := &concurrencyLimiter{
+		limit:     cfg.MaxInFlight,
+		queueSize: cfg.QueueSize,
+		adaptive:  cfg.Adaptive,
+	}
+	retryAfter := strconv.Itoa(ceilSeconds(cfg.RetryAfter))
+
+	return func(c *gin.Context) {
+		priority := cfg.Priority(c)
+		waitStart := time.Now()
+		ok, reason := limiter.acquire(c.Request.Context(), priority, cfg.QueueTimeout)
+		wait := time.Since(waitStart)
+		limit, inFlight := limiter.state()
+		attrs := []any{
+			slog.Int("limit", limit),
+			slog.Int("inFlight", inFlight),
+			slog.Int("priority", int(priority)),
+		}
+		if wait >= time.Millisecond {
+			attrs = append(attrs, slog.Duration("queued", wait))
+		}
+
+		if !ok {
+			AddLogAttrs(c, slog.Group("concurrency", append(attrs, slog.String("shed", reason))...))
+			c.Header("Retry-After", retryAfter)
+			MarkNotExecuted(c)
+			cfg.ShedHandler(c)
+			return
+		}
+		AddLogAttrs(c, slog.Group("concurrency", attrs...))
+
+		defer func() {
+			limiter.release(time.Since(common.GetStartTime(c)), c.Writer.Status() >= 500)
+		}()
+		c.Next()
+	}
+}
+
+// shed 原因
+const (
+	shedLimit        = "limit"
+	shedQueueTimeout = "queue_timeout"
+	shedCanceled     = "canceled"
+)
+
+// concurrencyLimiter 带优先级等待队列的信号量
+type concurrencyLimiter struct {
+	mu        sync.Mutex
+	limit     int
+	inFlight  int
+	queueSize int
+	queued    int
+	queues    [PriorityCritical][]*limiterWaiter // 按优先级分开的等待队列，critical 不排队
+	adaptive  AdaptiveLimit
+}
+
+type limiterWaiter struct {
+	ready   chan struct{}
+	granted bool
+}
+
+// acquire 获取一个名额，上限已满时排队等待，返回是否获取成功以及失败原因
+func (l *concurrencyLimiter) acquire(ctx context.Context, priority Priority, timeout time.Duration) (bool, string) {
+	priority = min(max(priority, PriorityLow), PriorityCritical)
+
+	l.mu.Lock()
+	if priority == PriorityCritical || l.inFlight < l.limit && l.queued == 0 {
+		l.inFlight++
+		l.mu.Unlock()
+		return true, ""
+	}
+	if priority == PriorityLow || l.queued >= l.queueSize {
+		l.mu.Unlock()
+		return false, shedLimit
+	}
+	w := &limiterWaiter{ready: make(chan struct{})}
+	l.queues[priority] = append(l.queues[priority], w)
+	l.queued++
+	l.mu.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	reason := shedQueueTimeout
+	select {
+	case <-w.ready:
+		return true, ""
+	case <-timer.C:
+	case <-ctx.Done():
+		reason = shedCanceled
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if w.granted {
+		// 超时的同时拿到了名额，直接使用
+		return true, ""
+	}
+	l.queues[priority] = slices.DeleteFunc(l.queues[priority], func(x *limiterWaiter) bool { return x == w })
+	l.queued--
+	return false, reason
+}
+
+// release 归还名额，更新自适应上限，并按优先级唤醒等待中的请求
+func (l *concurrencyLimiter) release(latency time.Duration, failed bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.adaptive != nil {
+		l.limit = max(l.adaptive.Update(l.limit, l.inFlight, latency, failed), 1)
+	}
+	l.inFlight--
+	for l.inFlight < l.limit && l.queued > 0 {
+		for p := PriorityHigh; p >= PriorityLow; p-- {
+			if len(l.queues[p]) == 0 {
+				continue
+			}
+			w := l.queues[p][0]
+			l.queues[p] = l.queues[p][1:]
+			l.queued--
+			l.inFlight++
+			w.granted = true
+			close(w.ready)
+			break
+		}
+	}
+}
+
+// state 返回当前的上限和处理中的请求数
+func (l *concurrencyLimiter) state() (limit, inFlight int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limit, l.inFlight
+}
+
+// AIMDConfig 加性增、乘性减的自适应上限配置
+type AIMDConfig struct {
+	// Min、Max 上限的范围，默认 1 和 1000
+	Min, Max int
+	// LatencyThreshold 请求耗时超过该值视为过载，为 0 时只按 5xx 判断
+	LatencyThreshold time.Duration
+	// Increase 每个正常请求增加的上限，默认 1
+	Increase int
+	// Backoff 过载时上限乘以的系数，默认 0.9
+	Backoff float64
+}
+
+type aimdLimit struct {
+	cfg AIMDConfig
+}
+
+// NewAIMDLimit 创建 AIMD 自适应上限：请求失败或超过耗时阈值时按比例减小上限，
+// 否则在并发数接近上限时逐步增大
+func NewAIMDLimit(cfg AIMDConfig) AdaptiveLimit {
+	cfg.Min, cfg.Max = limitRange(cfg.Min, cfg.Max)
+	if cfg.Increase <= 0 {
+		cfg.Increase = 1
+	}
+	if cfg.Backoff <= 0 || cfg.Backoff >= 1 {
+		cfg.Backoff = 0.9
+	}
+	return &aimdLimit{cfg: cfg}
+}
+
+func (a *aimdLimit) Update(limit, inFlight int, latency time.Duration, failed bool) int {
+	if failed || a.cfg.LatencyThreshold > 0 && latency > a.cfg.LatencyThreshold {
+		limit = int(float64(limit) * a.cfg.Backoff)
+	} else if inFlight*2 >= limit {
+		// 并发数远低于上限时说明上限不是瓶颈，不再增大
+		limit += a.cfg.Increase
+	}
+	return min(max(limit, a.cfg.Min), a.cfg.Max)
+}
+
+// GradientConfig 基于耗时梯度的自适应上限配置
+type GradientConfig struct {
+	// Min、Max 上限的范围，默认 1 和 1000
+	Min, Max int
+	// Smoothing 新上限的平滑系数，默认 0.2
+	Smoothing float64
+	// Tolerance 可以容忍的耗时增长倍数，默认 1.5
+	Tolerance float64
+	// LongWindow 长期平均耗时的样本窗口，默认 600
+	LongWindow int
+}
+
+type gradientLimit struct {
+	cfg      GradientConfig
+	estimate float64
+	longRTT  float64
+}
+
+// NewGradientLimit 创建梯度自适应上限：比较当前耗时与长期平均耗时，
+// 耗时上升时按比例减小上限，耗时稳定时留出 sqrt(上限) 的余量逐步增大
+func NewGradientLimit(cfg GradientConfig) AdaptiveLimit {
+	cfg.Min, cfg.Max = limitRange(cfg.Min, cfg.Max)
+	if cfg.Smoothing <= 0 || cfg.Smoothing > 1 {
+		cfg.Smoothing = 0.2
+	}
+	if cfg.Tolerance < 1 {
+		cfg.Tolerance = 1.5
+	}
+	if cfg.LongWindow <= 0 {
+		cfg.LongWindow = 600
+	}
+	return &gradientLimit{cfg: cfg}
+}
+
+func (g *gradientLimit) Update(limit, inFlight int, latency time.Duration, failed bool) int {
+	if g.estimate == 0 {
+		g.estimate = float64(limit)
+	}
+	rtt := float64(latency)
+	if rtt <= 0 {
+		return limit
+	}
+	if g.longRTT == 0 {
+		g.longRTT = rtt
+	} else {
+		g.longRTT += (rtt - g.longRTT) / float64(g.cfg.LongWindow)
+	}
+	// 长期耗时远高于当前耗时说明负载已经下降，加快长期耗时的恢复
+	if g.longRTT/rtt > 2 {
+		g.longRTT *= 0.95
+	}
+	if !failed && float64(inFlight)*2 < g.estimate {
+		return limit
+	}
+
+	gradient := math.Max(0.5, math.Min(1, g.cfg.Tolerance*g.longRTT/rtt))
+	if failed {
+		gradient = 0.5
+	}
+	next := g.estimate*gradient + math.Sqrt(g.estimate)
+	g.estimate = g.estimate*(1-g.cfg.Smoothing) + next*g.cfg.Smoothing
+	g.estimate = math.Min(math.Max(g.estimate, float64(g.cfg.Min)), float64(g.cfg.Max))
+	return int(g.estimate)
+}
+
+// limitRange 补充自适应上限范围的默认值
+func limitRange(lo, hi int) (int, int) {
+	if lo <= 0 {
+		lo = 1
+	}
+	if hi <= 0 {
+		hi = 1000
+	}
+	return lo, max(hi, lo)
+}
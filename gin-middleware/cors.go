@@ -0,0 +1,328 @@
+/**
+  @author: 35840
+  @date: 2026/10/19
+  @desc: CORS 跨域中间件，支持按路径覆盖配置
+**/
+
+package middleware
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CORSConfig CORS 中间件配置
+type CORSConfig struct {
+	// AllowOrigins 允许的来源：完整的来源如 "https://example.com"，通配子域名如 "https://*.example.com"
+	// （带端口时如 "https://*.example.com:8443"，只匹配该端口），或 "*" 表示允许所有来源
+	AllowOrigins []string
+	// AllowOriginPatterns 按正则表达式匹配来源，需要自行加 ^ 和 $ 锚定
+	AllowOriginPatterns []string
+	// AllowOriginFunc 自定义来源判断，在上面两项都不匹配时调用
+	AllowOriginFunc func(origin string) bool
+	// AllowMethods 允许的方法，默认 GET、HEAD、POST、PUT、PATCH、DELETE
+	AllowMethods []string
+	// AllowHeaders 允许的请求头，默认 Accept、Authorization、Content-Type 和 X-Request-ID，"*" 表示允许所有请求头
+	AllowHeaders []string
+	// ExposeHeaders 允许浏览器读取的响应头，默认 X-Request-ID
+	ExposeHeaders []string
+	// AllowCredentials 是否允许携带 Cookie 等凭证，此时 AllowOrigins 不能包含 "*"
+	AllowCredentials bool
+	// MaxAge 预检结果的缓存时间，默认 10 分钟，为负数时不输出 Access-Control-Max-Age
+	MaxAge time.Duration
+	// AllowPrivateNetwork 是否允许公网页面访问私有网络（Private Network Access）
+	AllowPrivateNetwork bool
+	// Overrides 按路径覆盖配置，按顺序匹配第一个，未匹配时使用当前配置。
+	// 预检请求通常匹配不到路由组上的中间件，因此路由组的差异化配置需要在这里按路径声明
+	Overrides []CORSOverride
+}
+
+// CORSOverride 按路径覆盖的 CORS 配置
+type CORSOverride struct {
+	// Paths 路径规则，与 LogRule.Paths 相同，支持 path.Match 通配符和 "/**" 前缀匹配
+	Paths  []string
+	Config CORSConfig
+}
+
+// CORS 允许 origins 中的来源跨域访问
+func CORS(origins ...string) gin.HandlerFunc {
+	return CORSWithConfig(CORSConfig{AllowOrigins: origins})
+}
+
+// CORSWithConfig 按配置创建 CORS 中间件，配置无效时 panic。
+// 预检请求在这里直接响应 204，被拒绝的预检请求响应 403 并记录日志
+func CORSWithConfig(cfg CORSConfig) gin.HandlerFunc {
+	type override struct {
+		paths  []string
+		policy *corsPolicy
+	}
+	base := newCORSPolicy(cfg)
+	overrides := make([]override, 0, len(cfg.Overrides))
+	for _, o := range cfg.Overrides {
+		overrides = append(overrides, override{paths: o.Paths, policy: newCORSPolicy(o.Config)})
+	}
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+		policy := base
+		for _, o := range overrides {
+			if matchAnyPath(o.paths, c.Request.URL.Path) {
+				policy = o.policy
+				break
+			}
+		}
+		if c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != "" {
+			policy.preflight(c, origin)
+			return
+		}
+		policy.actual(c, origin)
+		c.Next()
+	}
+}
+
+// corsWildcard 通配子域名来源，如 "https://*.example.com:8443" 保存为 https、".example.com" 和 8443
+type corsWildcard struct {
+	scheme string
+	suffix string
+	port   string
+}
+
+// parseCORSWildcard 解析通配子域名来源，格式无效时 panic
+func parseCORSWildcard(origin string) corsWildcard {
+	u, err := url.Parse(origin)
+	if err != nil || u.Scheme == "" || u.User != nil || u.Path != "" || u.RawQuery != "" || u.Fragment != "" ||
+		!strings.HasPrefix(u.Hostname(), "*.") || strings.Contains(u.Hostname()[2:], "*") {
+		panic(fmt.Sprintf("middleware: 无效的 CORS 通配来源 %q，格式应为 \"https://*.example.com\" 或 \"https://*.example.com:8443\"", origin))
+	}
+	return corsWildcard{scheme: u.Scheme, suffix: u.Hostname()[1:], port: u.Port()}
+}
+
+// match 判断来源是否为该通配的子域名，协议和端口必须完全一致
+func (w corsWildcard) match(origin *url.URL) bool {
+	host := origin.Hostname()
+	return origin.Scheme == w.scheme && origin.Port() == w.port &&
+		strings.HasSuffix(host, w.suffix) && len(host) > len(w.suffix)
+}
+
+// corsPolicy 预处理后的 CORS 配置
+type corsPolicy struct {
+	allowAll        bool
+	origins         map[string]struct{}
+	wildcards       []corsWildcard
+	patterns        []*regexp.Regexp
+	originFunc      func(string) bool
+	methods         map[string]struct{}
+	methodsValue    string
+	allowAllHeaders bool
+	headers         map[string]struct{}
+	exposeValue     string
+	credentials     bool
+	maxAge          string
+	privateNetwork  bool
+}
+
+func newCORSPolicy(cfg CORSConfig) *corsPolicy {
+	if len(cfg.AllowMethods) == 0 {
+		cfg.AllowMethods = []string{
+			http.MethodGet, http.MethodHead, http.MethodPost,
+			http.MethodPut, http.MethodPatch, http.MethodDelete,
+		}
+	}
+	if len(cfg.AllowHeaders) == 0 {
+		cfg.AllowHeaders = []string{"Accept", "Authorization", "Content-Type", DefaultRequestIDHeader}
+	}
+	if cfg.ExposeHeaders == nil {
+		cfg.ExposeHeaders = []string{DefaultRequestIDHeader}
+	}
+	if cfg.MaxAge == 0 {
+		cfg.MaxAge = 10 * time.Minute
+	}
+
+	p := &corsPolicy{
+		origins:        make(map[string]struct{}),
+		originFunc:     cfg.AllowOriginFunc,
+		methods:        make(map[string]struct{}),
+		methodsValue:   strings.ToUpper(strings.Join(cfg.AllowMethods, ", ")),
+		headers:        make(map[string]struct{}),
+		exposeValue:    strings.Join(cfg.ExposeHeaders, ", "),
+		credentials:    cfg.AllowCredentials,
+		privateNetwork: cfg.AllowPrivateNetwork,
+	}
+	for _, origin := range cfg.AllowOrigins {
+		origin = strings.ToLower(strings.TrimSuffix(origin, "/"))
+		switch {
+		case origin == "*":
+			if cfg.AllowCredentials {
+				panic("middleware: CORS 允许凭证时 AllowOrigins 不能包含 \"*\"，请列出具体的来源")
+			}
+			p.allowAll = true
+		case strings.Contains(origin, "://*."):
+			p.wildcards = append(p.wildcards, parseCORSWildcard(origin))
+		default:
+			p.origins[origin] = struct{}{}
+		}
+	}
+	for _, pattern := range cfg.AllowOriginPatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			panic(fmt.Sprintf("middleware: 无效的 CORS 来源正则 %q: %v", pattern, err))
+		}
+		p.patterns = append(p.patterns, re)
+	}
+	for _, m := range cfg.AllowMethods {
+		p.methods[strings.ToUpper(m)] = struct{}{}
+	}
+	for _, h := range cfg.AllowHeaders {
+		if h == "*" {
+			p.allowAllHeaders = true
+			continue
+		}
+		p.headers[strings.ToLower(h)] = struct{}{}
+	}
+	if cfg.MaxAge > 0 {
+		p.maxAge = strconv.Itoa(int(cfg.MaxAge / time.Second))
+	}
+	return p
+}
+
+// allowOrigin 判断来源是否允许
+func (p *corsPolicy) allowOrigin(origin string) bool {
+	if p.allowAll {
+		return true
+	}
+	lower := strings.ToLower(origin)
+	if _, ok := p.origins[lower]; ok {
+		return true
+	}
+	if len(p.wildcards) > 0 {
+		if u, err := url.Parse(lower); err == nil && u.User == nil && u.Path == "" && u.RawQuery == "" {
+			for _, w := range p.wildcards {
+				if w.match(u) {
+					return true
+				}
+			}
+		}
+	}
+	for _, re := range p.patterns {
+		if re.MatchString(origin) {
+			return true
+		}
+	}
+	return p.originFunc != nil && p.originFunc(origin)
+}
+
+// setOrigin 输出 Access-Control-Allow-Origin 和 Access-Control-Allow-Credentials
+func (p *corsPolicy) setOrigin(c *gin.Context, origin string) {
+	if p.allowAll && !p.credentials {
+		c.Header("Access-Control-Allow-Origin", "*")
+	} else {
+		c.Header("Access-Control-Allow-Origin", origin)
+	}
+	if p.credentials {
+		c.Header("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// actual 处理实际的跨域请求，来源不允许时不输出 CORS 响应头，由浏览器拦截
+func (p *corsPolicy) actual(c *gin.Context, origin string) {
+	if !p.allowAll || p.credentials {
+		// 响应随来源变化，需要告知缓存
+		c.Writer.Header().Add("Vary", "Origin")
+	}
+	if !p.allowOrigin(origin) {
+		return
+	}
+	p.setOrigin(c, origin)
+	if p.exposeValue != "" {
+		c.Header("Access-Control-Expose-Headers", p.exposeValue)
+	}
+}
+
+// preflight 处理预检请求
+func (p *corsPolicy) preflight(c *gin.Context, origin string) {
+	vary := c.Writer.Header()
+	vary.Add("Vary", "Origin")
+	vary.Add("Vary", "Access-Control-Request-Method")
+	vary.Add("Vary", "Access-Control-Request-Headers")
+
+	method := strings.ToUpper(c.GetHeader("Access-Control-Request-Method"))
+	requestHeaders := c.GetHeader("Access-Control-Request-Headers")
+	privateNetwork := c.GetHeader("Access-Control-Request-Private-Network") == "true"
+
+	var reason string
+	switch {
+	case !p.allowOrigin(origin):
+		reason = "origin"
+	case !p.allowMethod(method):
+		reason = "method"
+	case !p.allowHeaders(requestHeaders):
+		reason = "headers"
+	case privateNetwork && !p.privateNetwork:
+		reason = "private_network"
+	}
+	if reason != "" {
+		slog.WarnContext(
+			c.Request.Context(), "CORS 预检请求被拒绝",
+			slog.String("request_id", GetRequestID(c)),
+			slog.String("reason", reason),
+			slog.String("origin", origin),
+			slog.String("path", c.Request.URL.Path),
+			slog.String("requestMethod", method),
+			slog.String("requestHeaders", requestHeaders),
+		)
+		AddLogAttrs(c, slog.Group("cors", slog.String("rejected", reason), slog.String("origin", origin)))
+		c.AbortWithStatus(http.StatusForbidden)
+		return
+	}
+
+	p.setOrigin(c, origin)
+	c.Header("Access-Control-Allow-Methods", p.methodsValue)
+	if requestHeaders != "" {
+		c.Header("Access-Control-Allow-Headers", requestHeaders)
+	}
+	if p.maxAge != "" {
+		c.Header("Access-Control-Max-Age", p.maxAge)
+	}
+	if privateNetwork {
+		c.Header("Access-Control-Allow-Private-Network", "true")
+	}
+	c.AbortWithStatus(http.StatusNoContent)
+}
+
+// allowMethod 判断预检请求的方法是否允许，简单方法总是允许
+func (p *corsPolicy) allowMethod(method string) bool {
+	if method == http.MethodGet || method == http.MethodHead || method == http.MethodPost {
+		return true
+	}
+	_, ok := p.methods[method]
+	return ok
+}
+
+// allowHeaders 判断预检请求的请求头是否都允许
+func (p *corsPolicy) allowHeaders(requestHeaders string) bool {
+	if p.allowAllHeaders || requestHeaders == "" {
+		return true
+	}
+	for _, h := range strings.Split(requestHeaders, ",") {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if h == "" {
+			continue
+		}
+		if _, ok := p.headers[h]; !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,84 @@
+/**
+  @author: 35840
+  @date: 2026/10/19
+  @desc: 将 c.Errors 中的错误统一转换为 problem+json 响应
+**/
+
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huabingli/go-common/errors"
+)
+
+// Translator 根据 i18n key 返回面向用户的提示文案，没有翻译时返回 fallback
+type Translator func(c *gin.Context, key, fallback string) string
+
+// ErrorHandlerConfig 错误处理中间件配置
+type ErrorHandlerConfig struct {
+	Translator  Translator // 为空时直接使用错误码定义中的默认文案
+	TypeBaseURI string     // problem type 的前缀，如 "https://errors.example.com/"，为空时使用 about:blank
+}
+
+// ErrorHandler 使用默认配置创建错误处理中间件
+func ErrorHandler() gin.HandlerFunc {
+	return ErrorHandlerWithConfig(ErrorHandlerConfig{})
+}
+
+// ErrorHandlerWithConfig 创建错误处理中间件，
+// 处理器通过 c.Error 记录的错误（或 Handle 返回的错误）会被转换为 AppError 并输出 problem+json 响应，
+// 已经写过响应的请求不做处理。
+// c.Bind、c.BindJSON 等 MustBindWith 系列方法失败时已经写出 400 响应头，无法再输出 problem+json，
+// 处理器应使用 c.ShouldBind 并返回 errors.BadRequest.Wrap(err, ...) 等带错误码的错误
+func ErrorHandlerWithConfig(cfg ErrorHandlerConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+
+		ginErr := c.Errors.Last()
+		appErr, ok := errors.AsAppError(ginErr.Err)
+		if !ok {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(ginErr.Err, &maxBytesErr) {
+				appErr = errors.ToAppError(errors.PayloadTooLarge.Wrap(ginErr.Err, "请求体超过大小限制"))
+			} else {
+				appErr = errors.ToAppError(ginErr.Err)
+			}
+		}
+
+		if appErr.Status >= 500 {
+			slog.ErrorContext(c.Request.Context(), "请求处理失败", slog.Any("err", appErr))
+		}
+
+		AbortWithProblem(c, problemFromAppError(c, cfg, appErr))
+	}
+}
+
+// Handle 将返回 error 的处理函数转换为 gin.HandlerFunc，返回的错误交给 ErrorHandler 处理
+func Handle(fn func(c *gin.Context) error) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := fn(c); err != nil {
+			_ = c.Error(err)
+			c.Abort()
+		}
+	}
+}
+
+// problemFromAppError 将 AppError 转换为 Problem，内部细节不会写入响应
+func problemFromAppError(c *gin.Context, cfg ErrorHandlerConfig, appErr *errors.AppError) Problem {
+	p := NewProblem(appErr.Definition)
+	if cfg.Translator != nil && appErr.MessageKey != "" {
+		p.Title = cfg.Translator(c, appErr.MessageKey, appErr.Message)
+	}
+	if cfg.TypeBaseURI != "" {
+		p.Type = strings.TrimRight(cfg.TypeBaseURI, "/") + "/" + strings.ToLower(appErr.Code)
+	}
+	return p
+}
@@ -0,0 +1,278 @@
+/**
+  @author: 35840
+  @date: 2026/10/19
+  @desc: ETag 和条件请求：If-None-Match / If-Modified-Since 响应 304，If-Match / If-Unmodified-Since 响应 412
+**/
+
+package middleware
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huabingli/go-common"
+	"github.com/huabingli/go-common/errors"
+)
+
+// ResourceVersion 资源的当前版本，用于写请求的条件判断
+type ResourceVersion struct {
+	// ETag 资源当前的 ETag，带不带引号都可以
+	ETag string
+	// LastModified 资源的最后修改时间，为零值时不判断 If-Unmodified-Since
+	LastModified time.Time
+}
+
+// VersionResolver 在处理器之前查询资源的当前版本，资源不存在时 ok 返回 false
+type VersionResolver func(c *gin.Context) (version ResourceVersion, ok bool, err error)
+
+// ETagConfig ETag 中间件配置
+type ETagConfig struct {
+	// Weak 生成弱 ETag（W/"..."），适合语义相同但字节可能不同的响应
+	Weak bool
+	// MaxSize 最多缓冲的响应体字节数，超出或处理器调用 Flush 时直接输出，不生成 ETag，默认 1MB
+	MaxSize int
+	// Resolver 查询资源的当前版本，为空时不处理写请求的 If-Match 和 If-Unmodified-Since
+	Resolver VersionResolver
+	// PreconditionMethods 需要判断 If-Match 的方法，默认 PUT 和 PATCH
+	PreconditionMethods []string
+	// RequireIfMatch 为 true 时写请求必须带 If-Match 或 If-Unmodified-Since，否则响应 428
+	RequireIfMatch bool
+	// Skip 返回 true 时不处理
+	Skip func(c *gin.Context) bool
+}
+
+// ETag 使用默认配置创建 ETag 中间件，只处理 GET 和 HEAD 的 304
+func ETag() gin.HandlerFunc {
+	return ETagWithConfig(ETagConfig{})
+}
+
+// ETagWithConfig 创建 ETag 中间件。
+// GET 和 HEAD 的 200 响应会被缓冲，处理器没有设置 ETag 时按响应体的 SHA-256 生成，
+// 命中 If-None-Match（弱比较）或 If-Modified-Since（处理器设置了 Last-Modified）时响应 304；
+// 配置了 Resolver 时，写请求在处理器之前按资源当前版本判断 If-Match（强比较）和 If-Unmodified-Since，不满足时响应 412
+func ETagWithConfig(cfg ETagConfig) gin.HandlerFunc {
+	if cfg.MaxSize <= 0 {
+		cfg.MaxSize = 1 << 20
+	}
+	if len(cfg.PreconditionMethods) == 0 {
+		cfg.PreconditionMethods = []string{http.MethodPut, http.MethodPatch}
+	}
+	if cfg.RequireIfMatch && cfg.Resolver == nil {
+		panic("middleware: ETagConfig.RequireIfMatch 需要配置 Resolver")
+	}
+
+	return func(c *gin.Context) {
+		if cfg.Skip != nil && cfg.Skip(c) {
+			c.Next()
+			return
+		}
+
+		method := c.Request.Method
+		if method != http.MethodGet && method != http.MethodHead {
+			if cfg.Resolver != nil && slices.Contains(cfg.PreconditionMethods, method) && !checkPreconditions(c, &cfg) {
+				return
+			}
+			c.Next()
+			return
+		}
+
+		w := &etagWriter{ResponseWriter: c.Writer, maxSize: cfg.MaxSize}
+		c.Writer = w
+		defer func() {
+			c.Writer = w.ResponseWriter
+		}()
+
+		c.Next()
+
+		w.finish(c, cfg.Weak)
+	}
+}
+
+// checkPreconditions 判断写请求的 If-Match 和 If-Unmodified-Since，不满足时输出错误响应并返回 false
+func checkPreconditions(c *gin.Context, cfg *ETagConfig) bool {
+	ifMatch := c.GetHeader("If-Match")
+	ifUnmodifiedSince := c.GetHeader("If-Unmodified-Since")
+	if ifMatch == "" && ifUnmodifiedSince == "" {
+		if cfg.RequireIfMatch {
+			p := NewProblem(errors.PreconditionNeeded)
+			p.Detail = "修改资源需要携带 If-Match 请求头"
+			AbortWithProblem(c, p)
+			return false
+		}
+		return true
+	}
+
+	version, ok, err := cfg.Resolver(c)
+	if err != nil {
+		_ = c.Error(err)
+		c.Abort()
+		return false
+	}
+	etag := quoteETag(version.ETag)
+
+	matched := true
+	switch {
+	case ifMatch != "":
+		// 资源不存在时 If-Match: * 也不满足
+		matched = ok && (strings.TrimSpace(ifMatch) == "*" || etagListContains(ifMatch, etag, false))
+	case ok && !version.LastModified.IsZero():
+		// If-Match 存在时忽略 If-Unmodified-Since，无法解析的日期也忽略
+		if t, err := http.ParseTime(ifUnmodifiedSince); err == nil {
+			matched = !version.LastModified.Truncate(time.Second).After(t)
+		}
+	}
+	if matched {
+		return true
+	}
+
+	if ok && etag != "" {
+		c.Header("ETag", etag)
+	}
+	p := NewProblem(errors.PreconditionFailed)
+	p.Detail = "资源已被其他请求修改，请获取最新版本后重试"
+	AbortWithProblem(c, p)
+	return false
+}
+
+// notModified 判断 GET 和 HEAD 请求是否可以响应 304，If-None-Match 存在时忽略 If-Modified-Since
+func notModified(r *http.Request, etag, lastModified string) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return strings.TrimSpace(inm) == "*" || etagListContains(inm, etag, true)
+	}
+	ims := r.Header.Get("If-Modified-Since")
+	if ims == "" || lastModified == "" {
+		return false
+	}
+	modified, err := http.ParseTime(lastModified)
+	if err != nil {
+		return false
+	}
+	since, err := http.ParseTime(ims)
+	return err == nil && !modified.After(since)
+}
+
+// etagListContains 判断逗号分隔的 ETag 列表是否包含 etag，weak 为 true 时使用弱比较，否则弱 ETag 不匹配任何值
+func etagListContains(list, etag string, weak bool) bool {
+	if etag == "" || !weak && strings.HasPrefix(etag, "W/") {
+		return false
+	}
+	opaque := strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if strings.HasPrefix(candidate, "W/") {
+			if !weak {
+				continue
+			}
+			candidate = candidate[2:]
+		}
+		if candidate == opaque {
+			return true
+		}
+	}
+	return false
+}
+
+// quoteETag 给没有引号的 ETag 加上引号
+func quoteETag(etag string) string {
+	if etag == "" || strings.HasPrefix(etag, `"`) || strings.HasPrefix(etag, `W/"`) {
+		return etag
+	}
+	return `"` + etag + `"`
+}
+
+// StrongETag 按内容的 SHA-256 生成强 ETag
+func StrongETag(data []byte) string {
+	return `"` + common.SHA256V(data)[:32] + `"`
+}
+
+// WeakETag 按内容的 SHA-256 生成弱 ETag
+func WeakETag(data []byte) string {
+	return "W/" + StrongETag(data)
+}
+
+// etagWriter 缓冲 GET 和 HEAD 的响应体，用于生成 ETag 和输出 304
+type etagWriter struct {
+	gin.ResponseWriter
+	maxSize     int
+	buf         []byte
+	passthrough bool // 响应体过大或流式输出，不再缓冲
+}
+
+// bypass 写出已缓冲的内容，之后直接写出
+func (w *etagWriter) bypass() error {
+	w.passthrough = true
+	if len(w.buf) == 0 {
+		return nil
+	}
+	buf := w.buf
+	w.buf = nil
+	_, err := w.ResponseWriter.Write(buf)
+	return err
+}
+
+func (w *etagWriter) Write(p []byte) (int, error) {
+	if w.passthrough {
+		return w.ResponseWriter.Write(p)
+	}
+	w.buf = append(w.buf, p...)
+	if len(w.buf) > w.maxSize {
+		if err := w.bypass(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+func (w *etagWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// WriteHeaderNow 缓冲期间不写出响应头，否则无法再改为 304
+func (w *etagWriter) WriteHeaderNow() {
+	if w.passthrough {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+// Written 已经缓冲了响应体也视为已写出，避免后续的中间件再次写入响应
+func (w *etagWriter) Written() bool {
+	return len(w.buf) > 0 || w.ResponseWriter.Written()
+}
+
+// Flush 流式响应不生成 ETag
+func (w *etagWriter) Flush() {
+	_ = w.bypass()
+	w.ResponseWriter.Flush()
+}
+
+// finish 处理器结束后生成 ETag，满足条件时输出 304，否则写出缓冲的响应体
+func (w *etagWriter) finish(c *gin.Context, weak bool) {
+	if w.passthrough {
+		return
+	}
+	h := w.Header()
+	if w.Status() == http.StatusOK {
+		etag := h.Get("ETag")
+		if etag == "" {
+			if weak {
+				etag = WeakETag(w.buf)
+			} else {
+				etag = StrongETag(w.buf)
+			}
+			h.Set("ETag", etag)
+		}
+		if notModified(c.Request, etag, h.Get("Last-Modified")) {
+			for _, name := range []string{"Content-Type", "Content-Length", "Content-Encoding", "Transfer-Encoding"} {
+				h.Del(name)
+			}
+			w.buf = nil
+			w.ResponseWriter.WriteHeader(http.StatusNotModified)
+			w.ResponseWriter.WriteHeaderNow()
+			return
+		}
+	}
+	_ = w.bypass()
+}
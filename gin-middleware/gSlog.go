@@ -8,22 +8,111 @@ package middleware
 
 import (
 	"fmt"
+	"io"
 	"log/slog"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/huabingli/go-common"
-	"github.com/huabingli/go-common/jsonutil"
+	"github.com/huabingli/go-common/log"
 )
 
+// logAttrsContextKey gin.Context 中保存附加日志字段的 key
+const logAttrsContextKey = "_go-common/logAttrs"
+
+// AddLogAttrs 为当前请求的访问日志附加字段，供其他中间件和处理器记录限流等决策信息，
+// 只在 FormatSlog 下输出，不能在处理器之外的 goroutine 中调用
+func AddLogAttrs(c *gin.Context, attrs ...slog.Attr) {
+	if len(attrs) == 0 {
+		return
+	}
+	existing, _ := c.Get(logAttrsContextKey)
+	list, _ := existing.([]slog.Attr)
+	c.Set(logAttrsContextKey, append(list, attrs...))
+}
+
 // SkipLogFunc 定义类型：用于判断是否跳过日志记录的函数
 type SkipLogFunc func(c *gin.Context) bool
 
+// GSlogConfig GSlog 中间件配置
+type GSlogConfig struct {
+	// Skip 在处理器执行前判断是否跳过日志记录，跳过时也不会记录 body
+	Skip SkipLogFunc
+	// Rules 在处理器执行后按路径、方法、状态码和耗时决定是否记录或采样，见 LogRule
+	Rules []LogRule
+	// HeaderPolicy 非空时按该策略输出脱敏后的请求头
+	HeaderPolicy *HeaderPolicy
+	// Body 非空时记录请求体和响应体
+	Body *BodyCaptureConfig
+	// Format 访问日志格式，默认 FormatSlog；文本格式不包含 headers 和 body
+	Format AccessLogFormat
+	// Template Format 为 FormatTemplate 时使用的模板，如 "$remote_addr $request_time $status $request_id"
+	Template string
+	// AccessWriter 非空时访问日志写入该 Writer（如 lumberjack.Logger），与应用日志分开；为空时写入 slog 默认日志器
+	AccessWriter io.Writer
+	// Schema FormatSlog 下的字段布局，默认 SchemaLegacy
+	Schema LogSchema
+	// Timing 非空时记录分阶段耗时、输出 Server-Timing，并按阈值检测慢请求（日志级别至少为 Warn）；
+	// 各阶段耗时只在 FormatSlog 和 FormatTemplate（$timing、$phase_<name>、$slow 变量）下输出，
+	// 写入 AccessWriter 的文本行没有日志级别，慢请求只能通过 $slow 区分
+	Timing *TimingConfig
+}
+
+// GSlog 创建日志中间件，任意一个 skipFns 返回 true 时跳过日志记录
 func GSlog(skipFns ...SkipLogFunc) gin.HandlerFunc {
+	var fns []SkipLogFunc
+	for _, fn := range skipFns {
+		if fn != nil {
+			fns = append(fns, fn)
+		}
+	}
+
+	var cfg GSlogConfig
+	if len(fns) > 0 {
+		cfg.Skip = func(c *gin.Context) bool {
+			for _, fn := range fns {
+				if fn(c) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	return GSlogWithConfig(cfg)
+}
+
+// GSlogWithConfig 按配置创建日志中间件
+func GSlogWithConfig(cfg GSlogConfig) gin.HandlerFunc {
 
 	skipFn := func(c *gin.Context) bool { return false }
-	if len(skipFns) > 0 && skipFns[0] != nil {
-		skipFn = skipFns[0]
+	if cfg.Skip != nil {
+		skipFn = cfg.Skip
+	}
+	var capturer *bodyCapturer
+	if cfg.Body != nil {
+		capturer = newBodyCapturer(*cfg.Body)
+	}
+
+	var tmpl accessTemplate
+	switch cfg.Format {
+	case FormatCommon:
+		tmpl = compileAccessTemplate(CommonLogTemplate)
+	case FormatCombined:
+		tmpl = compileAccessTemplate(CombinedLogTemplate)
+	case FormatTemplate:
+		tmpl = compileAccessTemplate(cfg.Template)
+	}
+
+	var lineWriter *accessLineWriter
+	var accessLogger *slog.Logger
+	if cfg.AccessWriter != nil {
+		if tmpl != nil {
+			lineWriter = &accessLineWriter{w: cfg.AccessWriter}
+		} else {
+			accessLogger = slog.New(
+				slog.NewJSONHandler(cfg.AccessWriter, &slog.HandlerOptions{ReplaceAttr: log.ReplaceAttr(false)}),
+			)
+		}
 	}
 
 	return func(c *gin.Context) {
@@ -42,62 +131,114 @@ func GSlog(skipFns ...SkipLogFunc) gin.HandlerFunc {
 
 		fullPath := constructPath(path, raw)
 
+		var reqBody *teeReadCloser
+		var respWriter *captureWriter
+		if capturer != nil && capturer.enabled(c) {
+			reqBody = capturer.captureRequest(c)
+			respWriter = capturer.captureResponse(c)
+		}
+
+		var timing *common.RequestTiming
+		var timingWr *timingWriter
+		if cfg.Timing != nil {
+			timing = common.GetRequestTiming(c)
+			if cfg.Timing.ServerTiming {
+				timingWr = &timingWriter{ResponseWriter: c.Writer, timing: timing}
+				c.Writer = timingWr
+			}
+		}
+
 		c.Next() // 执行下一个中间件或最终的处理器函数
 
+		if timingWr != nil {
+			// 没有响应体的请求在这里补充 Server-Timing
+			timingWr.setHeader()
+			c.Writer = timingWr.ResponseWriter
+		}
+		if respWriter != nil {
+			c.Writer = respWriter.ResponseWriter
+		}
+
 		// 计算请求处理耗时
 		duration := time.Since(start)
 
 		status := c.Writer.Status()
 
-		clientIp := c.ClientIP()
+		if !shouldLog(cfg.Rules, method, path, status, duration) {
+			return
+		}
+
+		level := levelByStatus(status)
+		var slow bool
+		var threshold time.Duration
+		if cfg.Timing != nil {
+			threshold = cfg.Timing.threshold(c.FullPath())
+			slow = threshold > 0 && duration >= threshold
+			if slow && level < slog.LevelWarn {
+				level = slog.LevelWarn
+			}
+		}
+
+		if tmpl != nil {
+			line := tmpl.render(
+				&accessEntry{c: c, start: start, duration: duration, status: status, timing: timing, slow: slow},
+			)
+			if lineWriter != nil {
+				lineWriter.writeLine(line)
+			} else {
+				slog.Log(c.Request.Context(), level, line)
+			}
+			return
+		}
+
+		info := newRequestInfo(c, status, duration)
+		keys := cfg.Schema.keys()
+		attrs := cfg.Schema.attrs(info)
+		if cfg.Schema == SchemaLegacy {
+			// 构建请求摘要
+			summary := fmt.Sprintf("%3d %v %s %s %s", status, duration, info.clientIP, method, fullPath)
+			attrs = append([]slog.Attr{slog.String("summary", summary)}, attrs...)
+		}
+		if timing != nil {
+			attrs = append(attrs, timingAttrs(timing, slow, threshold)...)
+		}
+		if cfg.HeaderPolicy != nil {
+			attrs = append(attrs, cfg.HeaderPolicy.Attr(keys.headers, c.Request.Header))
+		}
+		if capturer != nil && !c.GetBool(bodyCaptureDisabledKey) {
+			if reqBody != nil {
+				attrs = append(attrs, slog.String(keys.requestBody, capturer.renderRequest(c.Request, reqBody)))
+			}
+			if respWriter != nil && respWriter.allowed {
+				attrs = append(
+					attrs,
+					slog.String(keys.responseBody, capturer.render(respWriter.Header().Get("Content-Type"), respWriter.body)),
+				)
+			}
+		}
+
+		if extra, ok := c.Get(logAttrsContextKey); ok {
+			attrs = append(attrs, extra.([]slog.Attr)...)
+		}
 
-		attrs := buildRequestLogAttrs(c, status, method, path, clientIp, fullPath, duration)
-		// 构建请求摘要
-		summary := fmt.Sprintf("%3d %v %s %s %s", status, duration, clientIp, method, fullPath)
 		// 将请求信息记录到日志
-		slog.LogAttrs(
+		logger := slog.Default()
+		if accessLogger != nil {
+			// 独立的访问日志不经过 log.Handler，需要自行补充 request ID
+			logger = accessLogger
+			if requestID := GetRequestID(c); requestID != "" {
+				attrs = append(attrs, slog.String("request_id", requestID))
+			}
+		}
+		logger.LogAttrs(
 			c.Request.Context(),
-			levelByStatus(status), // 设置日志级别为 Debug
+			level,
 			"HTTP request",
-			slog.String("summary", summary),
-			slog.Any("attrs", attrs),
+			attrs...,
 		)
 	}
 }
 
-func buildRequestLogAttrs(
-	c *gin.Context,
-	status int,
-	method, path, clientIp, fullPath string,
-	duration time.Duration,
-) []slog.Attr {
-	query := c.Request.URL.Query()
-	attrs := []slog.Attr{
-		slog.Int("status", status),
-		slog.String("duration", duration.String()),
-		slog.String("ip", clientIp),
-		slog.String("method", method),
-		slog.String("path", path),
-		slog.String("query", jsonutil.MustMarshalToString(&query)),
-		slog.String("fullPath", fullPath),
-		slog.Group(
-			"requestDuration",
-			slog.Int64("millis", duration.Milliseconds()),
-			slog.Float64("seconds", duration.Seconds()),
-		),
-	}
-
-	if errMsg := c.Errors.ByType(gin.ErrorTypePrivate).String(); errMsg != "" {
-		attrs = append(attrs, slog.String("errorMessage", errMsg))
-	}
-
-	if endpoint := c.FullPath(); endpoint != "" {
-		attrs = append(attrs, slog.String("endpoint", endpoint))
-	}
-
-	return attrs
-}
-
 // constructPath 函数组合路径和查询参数
 // 如果查询参数不为空，则返回 "path?raw"，否则仅返回 path。
 func constructPath(path, raw string) string {
@@ -0,0 +1,179 @@
+/**
+  @author: 35840
+  @date: 2026/10/19
+  @desc: 日志中请求头的脱敏策略，Recovery 和 GSlog 共用
+**/
+
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaskMode 敏感 header 的遮蔽方式
+type MaskMode int
+
+const (
+	MaskFull    MaskMode = iota // 整体替换为 "*"
+	MaskPartial                 // 只保留首尾各 4 个字符，过短的值整体替换
+	MaskHash                    // 替换为值的 HMAC-SHA256 前 16 位，便于关联同一个凭证而不泄露原值，见 HeaderPolicy.HashKey
+	MaskDrop                    // 不输出该 header
+)
+
+// DefaultSensitiveHeaders 默认需要遮蔽的 header
+var DefaultSensitiveHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"X-Api-Key",
+	"X-Auth-Token",
+	"X-Csrf-Token",
+	"X-Amz-Security-Token",
+}
+
+// HeaderPolicy header 脱敏策略
+type HeaderPolicy struct {
+	Allow            []string // 非空时只输出这些 header，Deny 中的 header 仍然会被遮蔽
+	Deny             []string // 需要遮蔽的 header
+	Mode             MaskMode // 遮蔽方式
+	MaskCookieValues bool     // Cookie 和 Set-Cookie 只遮蔽值，保留 cookie 名称
+	// HashKey MaskHash 使用的 HMAC 密钥，为空时使用进程启动时随机生成的密钥，
+	// 摘要只能在同一个进程内关联；需要跨实例或跨重启关联时配置固定的密钥
+	HashKey []byte
+}
+
+// processHashKey 进程内随机生成的 HMAC 密钥
+var processHashKey = func() []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic("middleware: 生成 header 摘要密钥失败: " + err.Error())
+	}
+	return key
+}()
+
+// DefaultHeaderPolicy 返回默认的脱敏策略：遮蔽 DefaultSensitiveHeaders 的副本，保留 cookie 名称，
+// 修改返回策略的 Deny 不会影响 DefaultSensitiveHeaders 和其他策略
+func DefaultHeaderPolicy() *HeaderPolicy {
+	return &HeaderPolicy{
+		Deny:             slices.Clone(DefaultSensitiveHeaders),
+		Mode:             MaskFull,
+		MaskCookieValues: true,
+	}
+}
+
+// Sanitize 返回脱敏后的 header 副本
+func (p *HeaderPolicy) Sanitize(h http.Header) http.Header {
+	result := make(http.Header, len(h))
+	for name, values := range h {
+		if len(p.Allow) > 0 && !containsFold(p.Allow, name) {
+			continue
+		}
+		if !containsFold(p.Deny, name) {
+			result[name] = append([]string(nil), values...)
+			continue
+		}
+		if p.Mode == MaskDrop {
+			continue
+		}
+
+		masked := make([]string, len(values))
+		for i, v := range values {
+			switch {
+			case p.MaskCookieValues && strings.EqualFold(name, "Cookie"):
+				masked[i] = p.maskCookie(v)
+			case p.MaskCookieValues && strings.EqualFold(name, "Set-Cookie"):
+				masked[i] = p.maskSetCookie(v)
+			default:
+				masked[i] = p.mask(v)
+			}
+		}
+		result[name] = masked
+	}
+	return result
+}
+
+// Attr 将脱敏后的 header 输出为 slog 分组，header 名称按字母排序，多个值用 ", " 连接
+func (p *HeaderPolicy) Attr(key string, h http.Header) slog.Attr {
+	sanitized := p.Sanitize(h)
+	names := make([]string, 0, len(sanitized))
+	for name := range sanitized {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	attrs := make([]slog.Attr, 0, len(names))
+	for _, name := range names {
+		attrs = append(attrs, slog.String(name, strings.Join(sanitized[name], ", ")))
+	}
+	return slog.Attr{Key: key, Value: slog.GroupValue(attrs...)}
+}
+
+// mask 按遮蔽方式处理单个值
+func (p *HeaderPolicy) mask(v string) string {
+	switch p.Mode {
+	case MaskPartial:
+		if utf8.RuneCountInString(v) <= 12 {
+			return "*"
+		}
+		runes := []rune(v)
+		return string(runes[:4]) + "****" + string(runes[len(runes)-4:])
+	case MaskHash:
+		key := p.HashKey
+		if len(key) == 0 {
+			key = processHashKey
+		}
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(v))
+		return "hmac:" + hex.EncodeToString(mac.Sum(nil)[:8])
+	default:
+		return "*"
+	}
+}
+
+// maskCookie 遮蔽 "a=1; b=2" 格式中每个 cookie 的值
+func (p *HeaderPolicy) maskCookie(v string) string {
+	pairs := strings.Split(v, ";")
+	for i, pair := range pairs {
+		name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			pairs[i] = p.mask(name)
+			continue
+		}
+		pairs[i] = name + "=" + p.mask(value)
+	}
+	return strings.Join(pairs, "; ")
+}
+
+// maskSetCookie 遮蔽 Set-Cookie 中 cookie 的值，保留 Path、Expires 等属性
+func (p *HeaderPolicy) maskSetCookie(v string) string {
+	first, attrs, hasAttrs := strings.Cut(v, ";")
+	name, value, ok := strings.Cut(strings.TrimSpace(first), "=")
+	if !ok {
+		return p.mask(v)
+	}
+	masked := name + "=" + p.mask(value)
+	if hasAttrs {
+		masked += ";" + attrs
+	}
+	return masked
+}
+
+// containsFold 忽略大小写判断 list 中是否包含 s
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
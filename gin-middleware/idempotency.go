@@ -0,0 +1,277 @@
+/**
+  @author: 35840
+  @date: 2026/10/19
+  @desc: Idempotency-Key 幂等中间件：保存第一次的响应，重试时原样重放
+**/
+
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huabingli/go-common"
+	"github.com/huabingli/go-common/errors"
+	"github.com/huabingli/go-common/idempotency"
+	"github.com/huabingli/go-common/jsonutil"
+)
+
+// DefaultIdempotencyHeader 默认的幂等 key header
+const DefaultIdempotencyHeader = "Idempotency-Key"
+
+// IdempotencyConfig 幂等中间件配置
+type IdempotencyConfig struct {
+	// Store 记录存储，必填
+	Store idempotency.Store
+	// Header 幂等 key 的 header，默认 DefaultIdempotencyHeader
+	Header string
+	// Methods 需要处理的方法，默认 POST 和 PATCH
+	Methods []string
+	// Required 为 true 时没有幂等 key 的请求响应 400
+	Required bool
+	// Scope 区分调用方，避免不同调用方使用相同的 key 时互相重放，默认 KeyFirst(KeyBySubject(), KeyByIP())
+	Scope KeyFunc
+	// TTL 完成的响应可以重放的时间，默认 24 小时
+	TTL time.Duration
+	// InFlightTTL 处理中记录的有效期，超过后认为第一个请求已经失败，默认 1 分钟
+	InFlightTTL time.Duration
+	// MaxBodySize 参与指纹计算的请求体和可以保存的响应体的最大字节数，默认 1MB，请求体超出时响应 413；
+	// 响应体超出时只保存状态码，重试时响应 409 而不是再次执行处理器
+	MaxBodySize int64
+}
+
+// Idempotency 使用 store 创建幂等中间件
+func Idempotency(store idempotency.Store) gin.HandlerFunc {
+	return IdempotencyWithConfig(IdempotencyConfig{Store: store})
+}
+
+// IdempotencyWithConfig 按配置创建幂等中间件：
+// 第一次请求正常处理并保存响应（状态码、响应头、响应体），之后相同 key 和相同请求体的请求直接重放；
+// 第一次请求还在处理时响应 409，相同 key 但请求体不同时响应 422。
+// 5xx 响应同样保存并重放，处理器 panic 时保存为 500；只有被内层的 RateLimit 或 ConcurrencyLimit 拒绝、
+// 处理器没有执行时才释放 key，客户端可以用同一个 key 重试。自定义的限流或降载中间件拒绝请求时调用 MarkNotExecuted
+func IdempotencyWithConfig(cfg IdempotencyConfig) gin.HandlerFunc {
+	if cfg.Store == nil {
+		panic("middleware: IdempotencyConfig.Store 不能为空")
+	}
+	if cfg.Header == "" {
+		cfg.Header = DefaultIdempotencyHeader
+	}
+	if len(cfg.Methods) == 0 {
+		cfg.Methods = []string{http.MethodPost, http.MethodPatch}
+	}
+	if cfg.Scope == nil {
+		cfg.Scope = KeyFirst(KeyBySubject(), KeyByIP())
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = 24 * time.Hour
+	}
+	if cfg.InFlightTTL <= 0 {
+		cfg.InFlightTTL = time.Minute
+	}
+	if cfg.MaxBodySize <= 0 {
+		cfg.MaxBodySize = 1 << 20
+	}
+
+	return func(c *gin.Context) {
+		if !slices.Contains(cfg.Methods, c.Request.Method) {
+			c.Next()
+			return
+		}
+		idemKey := c.GetHeader(cfg.Header)
+		if idemKey == "" {
+			if cfg.Required {
+				p := NewProblem(errors.BadRequest)
+				p.Detail = "缺少 " + cfg.Header + " 请求头"
+				AbortWithProblem(c, p)
+				return
+			}
+			c.Next()
+			return
+		}
+		if len(idemKey) > 255 {
+			p := NewProblem(errors.BadRequest)
+			p.Detail = cfg.Header + " 过长"
+			AbortWithProblem(c, p)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, cfg.MaxBodySize+1))
+		if err != nil {
+			def := errors.BadRequest
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				// 外层的 http.MaxBytesReader 限制了请求体大小，与 ErrorHandler 一致响应 413
+				def = errors.PayloadTooLarge
+			}
+			_ = c.Error(def.Wrap(err, "读取请求体失败"))
+			AbortWithProblem(c, NewProblem(def))
+			return
+		}
+		if int64(len(body)) > cfg.MaxBodySize {
+			p := NewProblem(errors.PayloadTooLarge)
+			p.Detail = "请求体过大，无法保证幂等"
+			AbortWithProblem(c, p)
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		ctx := c.Request.Context()
+		storeKey := cfg.Scope(c) + "|" + c.Request.Method + " " + c.FullPath() + "|" + idemKey
+		fingerprint := common.SHA256V(append([]byte(c.Request.Method+" "+c.Request.URL.RequestURI()+"\n"), body...))
+
+		existing, acquired, err := cfg.Store.Begin(ctx, storeKey, fingerprint, cfg.InFlightTTL)
+		if err != nil {
+			slog.ErrorContext(ctx, "读取幂等记录失败", slog.Any("err", err))
+			AbortWithProblem(c, NewProblem(errors.ServiceUnavailable))
+			return
+		}
+		if !acquired {
+			replayIdempotent(c, existing, fingerprint)
+			return
+		}
+
+		rw := &recordingWriter{ResponseWriter: c.Writer, body: &limitedBuffer{limit: int(cfg.MaxBodySize)}}
+		c.Writer = rw
+		finished := false
+		defer func() {
+			c.Writer = rw.ResponseWriter
+			if !finished {
+				// 处理器 panic 时副作用可能已经发生，保存为 500，重试时不再执行处理器
+				record := &idempotency.Record{Fingerprint: fingerprint, Completed: true, CreatedAt: time.Now()}
+				record.StatusCode, record.Header, record.Body = panicIdempotentResponse(c)
+				if err := cfg.Store.Complete(context.WithoutCancel(ctx), storeKey, record, cfg.TTL); err != nil {
+					slog.ErrorContext(ctx, "保存幂等记录失败", slog.Any("err", err))
+				}
+			}
+		}()
+
+		c.Next()
+		finished = true
+
+		if c.GetBool(notExecutedContextKey) {
+			// 被内层的限流或降载中间件拒绝，处理器没有执行，释放 key 允许重试
+			releaseIdempotent(ctx, cfg.Store, storeKey)
+			AddLogAttrs(c, slog.Group("idempotency", slog.String("result", "released")))
+			return
+		}
+		status := rw.Status()
+		// 处理器已经执行，包括 5xx 和 Timeout 的 504 在内，不论能否保存都不能释放 key，否则重试会再次执行处理器
+		record := &idempotency.Record{
+			Fingerprint: fingerprint,
+			Completed:   true,
+			StatusCode:  status,
+			Header:      rw.Header().Clone(),
+			CreatedAt:   time.Now(),
+		}
+		result := "stored"
+		if rw.body.truncated {
+			record.BodyTooLarge = true
+			result = "stored_without_body"
+		} else {
+			record.Body = bytes.Clone(rw.body.Bytes())
+		}
+		if err := cfg.Store.Complete(context.WithoutCancel(ctx), storeKey, record, cfg.TTL); err != nil {
+			// 保留处理中的记录，InFlightTTL 内的重试响应 409
+			slog.ErrorContext(ctx, "保存幂等记录失败", slog.Any("err", err))
+			AddLogAttrs(c, slog.Group("idempotency", slog.String("result", "store_failed")))
+			return
+		}
+		AddLogAttrs(c, slog.Group("idempotency", slog.String("result", result)))
+	}
+}
+
+// notExecutedContextKey gin.Context 中标记请求在处理器执行之前被拒绝
+const notExecutedContextKey = "_go-common/notExecuted"
+
+// MarkNotExecuted 标记请求在处理器执行之前被拒绝（限流、降载等），Idempotency 据此释放幂等 key
+func MarkNotExecuted(c *gin.Context) {
+	c.Set(notExecutedContextKey, true)
+}
+
+// panicIdempotentResponse 处理器 panic 时保存的响应
+func panicIdempotentResponse(c *gin.Context) (int, http.Header, []byte) {
+	p := NewProblem(errors.Internal)
+	p.Type = "about:blank"
+	p.Instance = c.Request.URL.Path
+	body, _ := jsonutil.Marshal(&p)
+	return p.Status, http.Header{"Content-Type": {ProblemContentType}}, body
+}
+
+// replayIdempotent 处理已存在的记录：处理中响应 409，指纹不同响应 422，响应体过大没有保存时响应 409，否则重放响应
+func replayIdempotent(c *gin.Context, record *idempotency.Record, fingerprint string) {
+	switch {
+	case record.Fingerprint != fingerprint:
+		AddLogAttrs(c, slog.Group("idempotency", slog.String("result", "mismatch")))
+		p := NewProblem(errors.Unprocessable)
+		p.Detail = "幂等 key 已被用于不同的请求"
+		AbortWithProblem(c, p)
+	case !record.Completed:
+		AddLogAttrs(c, slog.Group("idempotency", slog.String("result", "in_flight")))
+		c.Header("Retry-After", "1")
+		p := NewProblem(errors.Conflict)
+		p.Detail = "相同幂等 key 的请求正在处理中"
+		p.Retryable = true
+		AbortWithProblem(c, p)
+	case record.BodyTooLarge:
+		AddLogAttrs(c, slog.Group("idempotency", slog.String("result", "too_large")))
+		p := NewProblem(errors.Conflict)
+		p.Detail = "请求已处理（状态码 " + strconv.Itoa(record.StatusCode) + "），但响应过大无法重放"
+		AbortWithProblem(c, p)
+	default:
+		AddLogAttrs(c, slog.Group("idempotency", slog.String("result", "replayed")))
+		h := c.Writer.Header()
+		for k, v := range record.Header {
+			if !isReplayExcludedHeader(k) {
+				h[k] = slices.Clone(v)
+			}
+		}
+		h.Set("Idempotent-Replayed", "true")
+		c.Abort()
+		c.Status(record.StatusCode)
+		_, _ = c.Writer.Write(record.Body)
+		c.Writer.WriteHeaderNow()
+	}
+}
+
+// isReplayExcludedHeader 重放时不复制的响应头：由当前请求决定的值
+func isReplayExcludedHeader(name string) bool {
+	switch strings.ToLower(name) {
+	case "date", "set-cookie", "content-length", "server-timing",
+		strings.ToLower(DefaultRequestIDHeader), "ratelimit-limit", "ratelimit-remaining", "ratelimit-reset", "ratelimit-policy":
+		return true
+	default:
+		return false
+	}
+}
+
+// releaseIdempotent 删除处理中的记录
+func releaseIdempotent(ctx context.Context, store idempotency.Store, key string) {
+	if err := store.Release(context.WithoutCancel(ctx), key); err != nil {
+		slog.ErrorContext(ctx, "释放幂等记录失败", slog.Any("err", err))
+	}
+}
+
+// recordingWriter 记录响应体，用于保存幂等记录
+type recordingWriter struct {
+	gin.ResponseWriter
+	body *limitedBuffer
+}
+
+func (w *recordingWriter) Write(p []byte) (int, error) {
+	_, _ = w.body.Write(p)
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	_, _ = w.body.Write([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
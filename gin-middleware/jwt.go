@@ -0,0 +1,136 @@
+/**
+  @author: 35840
+  @date: 2026/10/19
+  @desc: JWT 认证中间件，校验通过后将声明保存到 gin.Context 和 c.Request.Context()
+**/
+
+package middleware
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	cerrors "github.com/huabingli/go-common/errors"
+	"github.com/huabingli/go-common/jwt"
+)
+
+// jwtClaimsContextKey gin.Context 中保存 JWT 声明的 key
+const jwtClaimsContextKey = "_go-common/jwtClaims"
+
+// JWTConfig JWT 认证中间件配置
+type JWTConfig struct {
+	// Verifier 令牌校验器，必填
+	Verifier *jwt.Verifier
+	// Cookie 非空时 Authorization 中没有 Bearer 令牌则从该 Cookie 读取
+	Cookie string
+	// Optional 为 true 时没有令牌的请求作为匿名请求继续处理，令牌无效时仍然拒绝
+	Optional bool
+	// Skip 返回 true 时不认证
+	Skip func(c *gin.Context) bool
+}
+
+// JWT 要求请求携带有效的 Bearer 令牌
+func JWT(verifier *jwt.Verifier) gin.HandlerFunc {
+	return JWTWithConfig(JWTConfig{Verifier: verifier})
+}
+
+// JWTWithConfig 按配置创建 JWT 认证中间件，认证失败时输出 401 problem+json 和 WWW-Authenticate 响应头，
+// 密钥来源（如 JWKS）故障时输出 503。
+// 声明同时写入 c.Request.Context()，配合 log.NewHandler(h, key, jwt.LogAttrs) 可以在每条日志中输出 user_id
+func JWTWithConfig(cfg JWTConfig) gin.HandlerFunc {
+	if cfg.Verifier == nil {
+		panic("middleware: JWTConfig.Verifier 不能为空")
+	}
+
+	return func(c *gin.Context) {
+		if cfg.Skip != nil && cfg.Skip(c) {
+			c.Next()
+			return
+		}
+
+		token := bearerToken(c.GetHeader("Authorization"))
+		if token == "" && cfg.Cookie != "" {
+			token, _ = c.Cookie(cfg.Cookie)
+		}
+		if token == "" {
+			if cfg.Optional {
+				c.Next()
+				return
+			}
+			c.Header("WWW-Authenticate", `Bearer`)
+			AbortWithProblem(c, NewProblem(cerrors.Unauthorized))
+			return
+		}
+
+		claims, err := cfg.Verifier.Verify(c.Request.Context(), token)
+		if err != nil {
+			AddLogAttrs(c, slog.Group("auth", slog.String("error", err.Error())))
+			if !isJWTRejection(err) {
+				// JWKS 等密钥来源故障不是令牌无效，响应 503，避免客户端误以为令牌失效而丢弃或重新登录
+				slog.ErrorContext(c.Request.Context(), "获取 JWT 验证密钥失败", slog.Any("err", err))
+				AbortWithProblem(c, NewProblem(cerrors.ServiceUnavailable))
+				return
+			}
+			c.Header("WWW-Authenticate", `Bearer error="invalid_token", error_description="`+jwtErrorDescription(err)+`"`)
+			p := NewProblem(cerrors.Unauthorized)
+			p.Detail = jwtErrorDescription(err)
+			AbortWithProblem(c, p)
+			return
+		}
+
+		c.Set(jwtClaimsContextKey, claims)
+		c.Request = c.Request.WithContext(jwt.NewContext(c.Request.Context(), claims))
+		c.Next()
+	}
+}
+
+// GetClaims 获取 JWT 中间件校验通过的声明，自定义声明通过 jwt.Custom 解码
+func GetClaims(c *gin.Context) (*jwt.Claims, bool) {
+	if val, ok := c.Get(jwtClaimsContextKey); ok {
+		claims, ok := val.(*jwt.Claims)
+		return claims, ok
+	}
+	return nil, false
+}
+
+// bearerToken 解析 "Bearer <token>"，scheme 不区分大小写
+func bearerToken(authorization string) string {
+	scheme, token, ok := strings.Cut(strings.TrimSpace(authorization), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// isJWTRejection 判断是否为令牌本身的问题，其余为密钥来源或配置错误
+func isJWTRejection(err error) bool {
+	return errors.Is(err, jwt.ErrMalformed) ||
+		errors.Is(err, jwt.ErrAlgorithm) ||
+		errors.Is(err, jwt.ErrUnknownKey) ||
+		errors.Is(err, jwt.ErrSignature) ||
+		errors.Is(err, jwt.ErrExpired) ||
+		errors.Is(err, jwt.ErrNotValidYet) ||
+		errors.Is(err, jwt.ErrIssuer) ||
+		errors.Is(err, jwt.ErrAudience)
+}
+
+// jwtErrorDescription 返回面向客户端的错误描述，不暴露密钥和 JWKS 等内部细节
+func jwtErrorDescription(err error) string {
+	switch {
+	case errors.Is(err, jwt.ErrExpired):
+		return "token expired"
+	case errors.Is(err, jwt.ErrNotValidYet):
+		return "token not valid yet"
+	case errors.Is(err, jwt.ErrIssuer), errors.Is(err, jwt.ErrAudience):
+		return "token not intended for this service"
+	case errors.Is(err, jwt.ErrSignature):
+		return "invalid signature"
+	case errors.Is(err, jwt.ErrMalformed):
+		return "malformed token"
+	default:
+		return http.StatusText(http.StatusUnauthorized)
+	}
+}
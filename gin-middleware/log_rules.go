@@ -0,0 +1,99 @@
+/**
+  @author: 35840
+  @date: 2026/10/19
+  @desc: GSlog 的跳过和采样规则
+**/
+
+package middleware
+
+import (
+	"math/rand/v2"
+	"path"
+	"strings"
+	"time"
+)
+
+// RuleAction 规则命中后的动作
+type RuleAction int
+
+const (
+	RuleLog    RuleAction = iota // 记录日志
+	RuleSkip                     // 不记录日志
+	RuleSample                   // 按 SampleRate 采样记录
+)
+
+// LogRule 日志规则，所有非空条件同时满足时命中。
+// 规则在处理器执行完之后按顺序匹配，第一条命中的规则生效，没有命中时记录日志
+type LogRule struct {
+	// Paths 请求路径的 glob，支持 path.Match 语法，以 "/**" 结尾时匹配该前缀下的所有路径，为空时不限制
+	Paths []string
+	// Methods 请求方法，忽略大小写，为空时不限制
+	Methods []string
+	// StatusMin、StatusMax 状态码范围（闭区间），为 0 时不限制
+	StatusMin int
+	StatusMax int
+	// MinLatency 耗时不小于该值时命中，为 0 时不限制
+	MinLatency time.Duration
+	// Action 命中后的动作
+	Action RuleAction
+	// SampleRate RuleSample 的采样率，取值 0~1
+	SampleRate float64
+}
+
+// match 判断规则是否命中
+func (r *LogRule) match(method, reqPath string, status int, latency time.Duration) bool {
+	if len(r.Paths) > 0 && !matchAnyPath(r.Paths, reqPath) {
+		return false
+	}
+	if len(r.Methods) > 0 && !containsFold(r.Methods, method) {
+		return false
+	}
+	if r.StatusMin > 0 && status < r.StatusMin {
+		return false
+	}
+	if r.StatusMax > 0 && status > r.StatusMax {
+		return false
+	}
+	if r.MinLatency > 0 && latency < r.MinLatency {
+		return false
+	}
+	return true
+}
+
+// shouldLog 按规则判断是否记录日志
+func shouldLog(rules []LogRule, method, reqPath string, status int, latency time.Duration) bool {
+	for i := range rules {
+		rule := &rules[i]
+		if !rule.match(method, reqPath, status, latency) {
+			continue
+		}
+		switch rule.Action {
+		case RuleSkip:
+			return false
+		case RuleSample:
+			return rand.Float64() < rule.SampleRate
+		default:
+			return true
+		}
+	}
+	return true
+}
+
+// matchAnyPath 判断路径是否匹配任意一个 glob
+func matchAnyPath(patterns []string, reqPath string) bool {
+	for _, pattern := range patterns {
+		if matchPath(pattern, reqPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPath 在 path.Match 的基础上支持以 "/**" 结尾的前缀匹配
+func matchPath(pattern, reqPath string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "/**"); ok {
+		return reqPath == prefix || strings.HasPrefix(reqPath, prefix+"/")
+	}
+	ok, err := path.Match(pattern, reqPath)
+	return err == nil && ok
+}
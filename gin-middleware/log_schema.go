@@ -0,0 +1,209 @@
+/**
+  @author: 35840
+  @date: 2026/10/19
+  @desc: GSlog 的字段布局：平铺的旧字段、Elastic Common Schema 和 OpenTelemetry HTTP 语义约定
+**/
+
+package middleware
+
+import (
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LogSchema 访问日志的字段布局
+type LogSchema int
+
+const (
+	SchemaLegacy LogSchema = iota // 平铺的旧字段名：status、duration、ip、method、path 等
+	SchemaECS                     // Elastic Common Schema：http.request.method、url.path、client.ip 等
+	SchemaOTel                    // OpenTelemetry HTTP 语义约定：http.request.method、url.path、client.address 等
+)
+
+// requestInfo 各字段布局共用的请求信息
+type requestInfo struct {
+	c        *gin.Context
+	status   int
+	duration time.Duration
+	clientIP string
+	errMsg   string
+}
+
+// newRequestInfo 收集请求信息，需要在处理器执行完之后调用
+func newRequestInfo(c *gin.Context, status int, duration time.Duration) *requestInfo {
+	return &requestInfo{
+		c:        c,
+		status:   status,
+		duration: duration,
+		clientIP: c.ClientIP(),
+		errMsg:   c.Errors.ByType(gin.ErrorTypePrivate).String(),
+	}
+}
+
+// scheme 请求的协议
+func (r *requestInfo) scheme() string {
+	if r.c.Request.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// protocolVersion HTTP 协议版本，如 "1.1"、"2"
+func (r *requestInfo) protocolVersion() string {
+	req := r.c.Request
+	if req.ProtoMajor >= 2 && req.ProtoMinor == 0 {
+		return strconv.Itoa(req.ProtoMajor)
+	}
+	return strconv.Itoa(req.ProtoMajor) + "." + strconv.Itoa(req.ProtoMinor)
+}
+
+// requestSize 请求体大小，未知时为 0
+func (r *requestInfo) requestSize() int64 {
+	return max(r.c.Request.ContentLength, 0)
+}
+
+// responseSize 响应体大小
+func (r *requestInfo) responseSize() int {
+	return max(r.c.Writer.Size(), 0)
+}
+
+// schemaKeys 不同字段布局下 headers 和 body 的字段名
+type schemaKeys struct {
+	headers      string
+	requestBody  string
+	responseBody string
+}
+
+// keys 返回字段布局对应的附加字段名
+func (s LogSchema) keys() schemaKeys {
+	switch s {
+	case SchemaECS:
+		return schemaKeys{
+			headers:      "http.request.headers",
+			requestBody:  "http.request.body.content",
+			responseBody: "http.response.body.content",
+		}
+	case SchemaOTel:
+		return schemaKeys{
+			headers:      "http.request.header",
+			requestBody:  "http.request.body",
+			responseBody: "http.response.body",
+		}
+	default:
+		return schemaKeys{headers: "headers", requestBody: "requestBody", responseBody: "responseBody"}
+	}
+}
+
+// attrs 按字段布局生成日志字段
+func (s LogSchema) attrs(info *requestInfo) []slog.Attr {
+	switch s {
+	case SchemaECS:
+		return ecsAttrs(info)
+	case SchemaOTel:
+		return otelAttrs(info)
+	default:
+		return legacyAttrs(info)
+	}
+}
+
+// legacyAttrs 平铺的旧字段，query 只输出原始查询字符串
+func legacyAttrs(info *requestInfo) []slog.Attr {
+	req := info.c.Request
+	attrs := []slog.Attr{
+		slog.Int("status", info.status),
+		slog.String("duration", info.duration.String()),
+		slog.String("ip", info.clientIP),
+		slog.String("method", req.Method),
+		slog.String("path", req.URL.Path),
+		slog.String("query", req.URL.RawQuery),
+		slog.Group(
+			"requestDuration",
+			slog.Int64("millis", info.duration.Milliseconds()),
+			slog.Float64("seconds", info.duration.Seconds()),
+		),
+		slog.String("userAgent", req.UserAgent()),
+		slog.String("referer", req.Referer()),
+		slog.Int64("requestSize", info.requestSize()),
+		slog.Int("responseSize", info.responseSize()),
+		slog.String("protocol", req.Proto),
+		slog.String("host", req.Host),
+	}
+
+	if info.errMsg != "" {
+		attrs = append(attrs, slog.String("errorMessage", info.errMsg))
+	}
+
+	if endpoint := info.c.FullPath(); endpoint != "" {
+		attrs = append(attrs, slog.String("endpoint", endpoint))
+	}
+
+	return attrs
+}
+
+// ecsAttrs Elastic Common Schema 字段，event.duration 单位为纳秒
+func ecsAttrs(info *requestInfo) []slog.Attr {
+	req := info.c.Request
+	attrs := []slog.Attr{
+		slog.String("http.request.method", req.Method),
+		slog.Int("http.response.status_code", info.status),
+		slog.String("http.version", info.protocolVersion()),
+		slog.Int64("http.request.body.bytes", info.requestSize()),
+		slog.Int("http.response.body.bytes", info.responseSize()),
+		slog.String("url.path", req.URL.Path),
+		slog.String("url.original", req.RequestURI),
+		slog.String("url.scheme", info.scheme()),
+		slog.String("url.domain", req.Host),
+		slog.String("client.ip", info.clientIP),
+		slog.Int64("event.duration", info.duration.Nanoseconds()),
+	}
+	if req.URL.RawQuery != "" {
+		attrs = append(attrs, slog.String("url.query", req.URL.RawQuery))
+	}
+	if ua := req.UserAgent(); ua != "" {
+		attrs = append(attrs, slog.String("user_agent.original", ua))
+	}
+	if referer := req.Referer(); referer != "" {
+		attrs = append(attrs, slog.String("http.request.referrer", referer))
+	}
+	if info.errMsg != "" {
+		attrs = append(attrs, slog.String("error.message", info.errMsg))
+	}
+	return attrs
+}
+
+// otelAttrs OpenTelemetry HTTP 语义约定字段，http.server.request.duration 单位为秒
+func otelAttrs(info *requestInfo) []slog.Attr {
+	req := info.c.Request
+	attrs := []slog.Attr{
+		slog.String("http.request.method", req.Method),
+		slog.Int("http.response.status_code", info.status),
+		slog.String("url.path", req.URL.Path),
+		slog.String("url.scheme", info.scheme()),
+		slog.String("client.address", info.clientIP),
+		slog.String("server.address", req.Host),
+		slog.String("network.protocol.name", "http"),
+		slog.String("network.protocol.version", info.protocolVersion()),
+		slog.Int64("http.request.body.size", info.requestSize()),
+		slog.Int("http.response.body.size", info.responseSize()),
+		slog.Float64("http.server.request.duration", info.duration.Seconds()),
+	}
+	if route := info.c.FullPath(); route != "" {
+		attrs = append(attrs, slog.String("http.route", route))
+	}
+	if req.URL.RawQuery != "" {
+		attrs = append(attrs, slog.String("url.query", req.URL.RawQuery))
+	}
+	if ua := req.UserAgent(); ua != "" {
+		attrs = append(attrs, slog.String("user_agent.original", ua))
+	}
+	if info.status >= 500 {
+		attrs = append(attrs, slog.String("error.type", strconv.Itoa(info.status)))
+	}
+	if info.errMsg != "" {
+		attrs = append(attrs, slog.String("exception.message", info.errMsg))
+	}
+	return attrs
+}
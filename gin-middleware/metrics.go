@@ -0,0 +1,148 @@
+/**
+  @author: 35840
+  @date: 2026/10/19
+  @desc: HTTP 指标中间件，输出 Prometheus 文本格式
+**/
+
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huabingli/go-common"
+	"github.com/huabingli/go-common/metrics"
+)
+
+const (
+	// unmatchedRoute 没有匹配到路由的请求使用的 route 标签
+	unmatchedRoute = "<unmatched>"
+	// overflowRoute 路由数量超过上限后使用的 route 标签
+	overflowRoute = "<other>"
+)
+
+// DefaultSizeBuckets 默认的请求和响应大小桶（字节）
+var DefaultSizeBuckets = []float64{100, 1000, 10_000, 100_000, 1_000_000, 10_000_000}
+
+// MetricsConfig HTTP 指标中间件配置
+type MetricsConfig struct {
+	// Registry 指标注册表，默认 metrics.DefaultRegistry
+	Registry *metrics.Registry
+	// Namespace 指标名前缀，默认 "http"
+	Namespace string
+	// Buckets 延迟直方图的桶（秒），默认 metrics.DefBuckets
+	Buckets []float64
+	// SizeBuckets 请求和响应大小直方图的桶（字节），默认 DefaultSizeBuckets
+	SizeBuckets []float64
+	// MaxRoutes 最多记录的路由模板数量，超出后归入 "<other>"，默认 500
+	MaxRoutes int
+	// Skip 返回 true 时不记录，如 /metrics 本身
+	Skip func(c *gin.Context) bool
+}
+
+// Metrics 使用默认配置创建 HTTP 指标中间件
+func Metrics() gin.HandlerFunc {
+	return MetricsWithConfig(MetricsConfig{})
+}
+
+// MetricsWithConfig 创建 HTTP 指标中间件，按路由模板、请求方法和状态码分类记录：
+// 请求数、延迟、处理中的请求数、请求和响应大小
+func MetricsWithConfig(cfg MetricsConfig) gin.HandlerFunc {
+	if cfg.Registry == nil {
+		cfg.Registry = metrics.DefaultRegistry
+	}
+	if cfg.Namespace == "" {
+		cfg.Namespace = "http"
+	}
+	if len(cfg.SizeBuckets) == 0 {
+		cfg.SizeBuckets = DefaultSizeBuckets
+	}
+	if cfg.MaxRoutes <= 0 {
+		cfg.MaxRoutes = 500
+	}
+
+	reg, ns := cfg.Registry, cfg.Namespace
+	requests := reg.NewCounterVec(ns+"_requests_total", "HTTP 请求总数", "route", "method", "status")
+	latency := reg.NewHistogramVec(ns+"_request_duration_seconds", "HTTP 请求处理耗时（秒）", cfg.Buckets, "route", "method", "status")
+	inFlight := reg.NewGaugeVec(ns+"_requests_in_flight", "正在处理的 HTTP 请求数", "route", "method")
+	requestSize := reg.NewHistogramVec(ns+"_request_size_bytes", "HTTP 请求体大小（字节）", cfg.SizeBuckets, "route", "method")
+	responseSize := reg.NewHistogramVec(ns+"_response_size_bytes", "HTTP 响应体大小（字节）", cfg.SizeBuckets, "route", "method", "status")
+	routes := &routeLimiter{max: cfg.MaxRoutes}
+
+	return func(c *gin.Context) {
+		start := common.GetStartTime(c)
+		if cfg.Skip != nil && cfg.Skip(c) {
+			c.Next()
+			return
+		}
+
+		route := routes.label(c.FullPath())
+		method := normalizeMethod(c.Request.Method)
+		gauge := inFlight.With(route, method)
+		gauge.Inc()
+		defer gauge.Dec()
+
+		c.Next()
+
+		status := statusClass(c.Writer.Status())
+		requests.With(route, method, status).Inc()
+		latency.With(route, method, status).Observe(time.Since(start).Seconds())
+		requestSize.With(route, method).Observe(float64(max(c.Request.ContentLength, 0)))
+		responseSize.With(route, method, status).Observe(float64(max(c.Writer.Size(), 0)))
+	}
+}
+
+// MetricsHandler 输出 Prometheus 文本格式指标的处理函数，reg 为空时使用 metrics.DefaultRegistry
+func MetricsHandler(reg *metrics.Registry) gin.HandlerFunc {
+	if reg == nil {
+		reg = metrics.DefaultRegistry
+	}
+	return gin.WrapH(reg.Handler())
+}
+
+// routeLimiter 限制 route 标签的数量，防止标签基数无限增长
+type routeLimiter struct {
+	max   int
+	count atomic.Int64
+	seen  sync.Map
+}
+
+// label 返回路由模板对应的 route 标签
+func (l *routeLimiter) label(route string) string {
+	if route == "" {
+		return unmatchedRoute
+	}
+	if _, ok := l.seen.Load(route); ok {
+		return route
+	}
+	if l.count.Load() >= int64(l.max) {
+		return overflowRoute
+	}
+	if _, loaded := l.seen.LoadOrStore(route, struct{}{}); !loaded {
+		l.count.Add(1)
+	}
+	return route
+}
+
+// normalizeMethod 非标准的请求方法统一为 OTHER
+func normalizeMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	default:
+		return "OTHER"
+	}
+}
+
+// statusClass 将状态码归类为 "2xx"、"4xx" 等
+func statusClass(status int) string {
+	if status < 100 || status > 599 {
+		return "unknown"
+	}
+	return strconv.Itoa(status/100) + "xx"
+}
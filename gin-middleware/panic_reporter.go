@@ -0,0 +1,346 @@
+/**
+  @author: 35840
+  @date: 2026/10/19
+  @desc: panic 事件上报：指纹去重、webhook、文件和内存上报器
+**/
+
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/huabingli/go-common"
+	"github.com/huabingli/go-common/errors"
+	"github.com/huabingli/go-common/jsonutil"
+)
+
+// fingerprintFrames 计算指纹时使用的栈顶帧数
+const fingerprintFrames = 5
+
+// PanicEvent 一次 panic 事件
+type PanicEvent struct {
+	EventID     string         `json:"eventId"`
+	Fingerprint string         `json:"fingerprint"`
+	Time        time.Time      `json:"time"`
+	Type        string         `json:"type"`  // panic 值的类型
+	Value       string         `json:"value"` // panic 值
+	Stack       []errors.Frame `json:"stack"` // 从 panic 发生位置开始的调用栈
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	Route       string         `json:"route,omitempty"`
+	RequestID   string         `json:"requestId,omitempty"`
+	TraceID     string         `json:"traceId,omitempty"`
+	Suppressed  int            `json:"suppressed"` // 上一个去重窗口内被忽略的相同 panic 次数
+}
+
+// Reporter panic 事件上报接口
+type Reporter interface {
+	Report(ctx context.Context, event PanicEvent) error
+}
+
+// panicStack 从 recover 处记录的调用栈中去掉 recover 和 runtime 的帧，只保留 panic 发生位置开始的部分
+func panicStack(frames []errors.Frame) []errors.Frame {
+	for i, f := range frames {
+		if f.Function != "runtime.gopanic" {
+			continue
+		}
+		rest := frames[i+1:]
+		for len(rest) > 0 && strings.HasPrefix(rest[0].Function, "runtime.") {
+			rest = rest[1:]
+		}
+		return rest
+	}
+	return frames
+}
+
+// panicFingerprint 按 panic 值类型和栈顶若干帧的函数名计算指纹，行号变化不影响指纹
+func panicFingerprint(typ string, frames []errors.Frame) string {
+	var sb strings.Builder
+	sb.WriteString(typ)
+	for i, f := range frames {
+		if i >= fingerprintFrames {
+			break
+		}
+		sb.WriteByte('|')
+		sb.WriteString(f.Function)
+	}
+	return common.GenerateMD5Hash(sb.String())
+}
+
+// panicDeduper 按指纹在时间窗口内去重
+type panicDeduper struct {
+	mu      sync.Mutex
+	window  time.Duration
+	entries map[string]*dedupEntry
+}
+
+type dedupEntry struct {
+	windowStart time.Time
+	suppressed  int
+}
+
+func newPanicDeduper(window time.Duration) *panicDeduper {
+	return &panicDeduper{window: window, entries: make(map[string]*dedupEntry)}
+}
+
+// observe 记录一次 panic，返回是否需要上报以及上一个窗口内被忽略的次数
+func (d *panicDeduper) observe(fingerprint string, now time.Time) (bool, int) {
+	if d.window <= 0 {
+		return true, 0
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	entry, ok := d.entries[fingerprint]
+	if ok && now.Sub(entry.windowStart) < d.window {
+		entry.suppressed++
+		return false, 0
+	}
+
+	suppressed := 0
+	if ok {
+		suppressed = entry.suppressed
+	}
+	d.entries[fingerprint] = &dedupEntry{windowStart: now}
+
+	// 指纹过多时清理已经过期的窗口
+	if len(d.entries) > 1024 {
+		for fp, e := range d.entries {
+			if now.Sub(e.windowStart) >= d.window {
+				delete(d.entries, fp)
+			}
+		}
+	}
+	return true, suppressed
+}
+
+// MemoryReporter 将事件保存在内存中，用于测试
+type MemoryReporter struct {
+	mu     sync.Mutex
+	events []PanicEvent
+}
+
+// NewMemoryReporter 创建内存上报器
+func NewMemoryReporter() *MemoryReporter {
+	return &MemoryReporter{}
+}
+
+// Report 保存事件
+func (r *MemoryReporter) Report(_ context.Context, event PanicEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+	return nil
+}
+
+// Events 返回已保存事件的副本
+func (r *MemoryReporter) Events() []PanicEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]PanicEvent(nil), r.events...)
+}
+
+// Reset 清空已保存的事件
+func (r *MemoryReporter) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = nil
+}
+
+// FileReporter 将事件以 JSON Lines 格式追加写入文件
+type FileReporter struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewFileReporter 创建文件上报器，文件不存在时自动创建
+func NewFileReporter(path string) (*FileReporter, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileReporter{file: f}, nil
+}
+
+// Report 写入一行事件
+func (r *FileReporter) Report(_ context.Context, event PanicEvent) error {
+	data, err := jsonutil.Marshal(&event)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, err = r.file.Write(append(data, '\n'))
+	return err
+}
+
+// Close 关闭文件
+func (r *FileReporter) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.file.Close()
+}
+
+// WebhookReporter 以 Sentry envelope 格式 POST 事件，可以直接对接 Sentry 或兼容的服务
+type WebhookReporter struct {
+	URL         string
+	Client      *http.Client      // 为空时使用 5 秒超时的默认客户端
+	Headers     map[string]string // 额外的请求头
+	Environment string
+	Release     string
+}
+
+// NewWebhookReporter 创建 webhook 上报器
+func NewWebhookReporter(url string) *WebhookReporter {
+	return &WebhookReporter{URL: url}
+}
+
+// NewSentryReporter 根据 Sentry DSN（https://key@host/projectID）创建上报器
+func NewSentryReporter(dsn string) (*WebhookReporter, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, err
+	}
+	projectID := strings.Trim(u.Path, "/")
+	if u.User == nil || u.User.Username() == "" || projectID == "" {
+		return nil, fmt.Errorf("无效的 Sentry DSN: %s", dsn)
+	}
+	endpoint := fmt.Sprintf("%s://%s/api/%s/envelope/", u.Scheme, u.Host, projectID)
+	return &WebhookReporter{
+		URL: endpoint,
+		Headers: map[string]string{
+			"X-Sentry-Auth": "Sentry sentry_version=7, sentry_client=go-common/1.0, sentry_key=" + u.User.Username(),
+		},
+	}, nil
+}
+
+// Report 发送事件
+func (r *WebhookReporter) Report(ctx context.Context, event PanicEvent) error {
+	body, err := r.envelope(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-sentry-envelope")
+	for k, v := range r.Headers {
+		req.Header.Set(k, v)
+	}
+
+	client := r.Client
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("panic 事件上报失败: %s", resp.Status)
+	}
+	return nil
+}
+
+// sentryFrame Sentry 栈帧
+type sentryFrame struct {
+	Function string `json:"function"`
+	AbsPath  string `json:"abs_path"`
+	Filename string `json:"filename"`
+	Lineno   int    `json:"lineno"`
+	InApp    bool   `json:"in_app"`
+}
+
+// envelope 按 Sentry envelope 格式编码：envelope 头、item 头、事件内容各占一行
+func (r *WebhookReporter) envelope(event PanicEvent) ([]byte, error) {
+	// Sentry 要求栈帧从最外层调用开始排列
+	projectPath := common.GetProjectPath()
+	frames := make([]sentryFrame, 0, len(event.Stack))
+	for i := len(event.Stack) - 1; i >= 0; i-- {
+		f := event.Stack[i]
+		inApp := projectPath != "" && strings.HasPrefix(f.File, projectPath)
+		filename := f.File
+		if inApp {
+			filename = strings.TrimLeft(strings.TrimPrefix(f.File, projectPath), `\/`)
+		}
+		frames = append(
+			frames, sentryFrame{
+				Function: f.Function,
+				AbsPath:  f.File,
+				Filename: filename,
+				Lineno:   f.Line,
+				InApp:    inApp,
+			},
+		)
+	}
+
+	payload := map[string]any{
+		"event_id":    event.EventID,
+		"timestamp":   event.Time.UTC().Format(time.RFC3339Nano),
+		"platform":    "go",
+		"level":       "fatal",
+		"logger":      "gin-middleware.Recovery",
+		"fingerprint": []string{event.Fingerprint},
+		"exception": map[string]any{
+			"values": []any{
+				map[string]any{
+					"type":       event.Type,
+					"value":      event.Value,
+					"mechanism":  map[string]any{"type": "panic", "handled": true},
+					"stacktrace": map[string]any{"frames": frames},
+				},
+			},
+		},
+		"request": map[string]any{
+			"method": event.Method,
+			"url":    event.URL,
+		},
+		"tags": map[string]string{
+			"route":      event.Route,
+			"request_id": event.RequestID,
+			"trace_id":   event.TraceID,
+		},
+		"extra": map[string]any{
+			"suppressed": event.Suppressed,
+		},
+	}
+	if r.Environment != "" {
+		payload["environment"] = r.Environment
+	}
+	if r.Release != "" {
+		payload["release"] = r.Release
+	}
+
+	data, err := jsonutil.Marshal(&payload)
+	if err != nil {
+		return nil, err
+	}
+	header := map[string]any{
+		"event_id": event.EventID,
+		"sent_at":  time.Now().UTC().Format(time.RFC3339Nano),
+	}
+	headerData, err := jsonutil.Marshal(&header)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.Write(headerData)
+	buf.WriteByte('\n')
+	buf.WriteString(`{"type":"event","content_type":"application/json","length":` + strconv.Itoa(len(data)) + `}`)
+	buf.WriteByte('\n')
+	buf.Write(data)
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,86 @@
+/**
+  @author: 35840
+  @date: 2026/10/19
+  @desc: RFC 7807 problem+json 错误响应
+**/
+
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/render"
+	"github.com/huabingli/go-common/errors"
+	"github.com/huabingli/go-common/jsonutil"
+)
+
+// ProblemContentType RFC 7807 错误响应的 Content-Type
+const ProblemContentType = "application/problem+json"
+
+// Problem RFC 7807 错误响应
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	Code      string `json:"code,omitempty"`
+	RequestID string `json:"requestId,omitempty"`
+	TraceID   string `json:"traceId,omitempty"`
+	Retryable bool   `json:"retryable,omitempty"`
+
+	// Extensions 额外的扩展字段，与上面的字段平铺输出
+	Extensions map[string]any `json:"-"`
+}
+
+// MarshalJSON 将扩展字段与标准字段平铺输出，标准字段优先
+func (p Problem) MarshalJSON() ([]byte, error) {
+	type plain Problem
+	data, err := jsonutil.Marshal((*plain)(&p))
+	if err != nil || len(p.Extensions) == 0 {
+		return data, err
+	}
+
+	merged := make(map[string]any, len(p.Extensions)+8)
+	for k, v := range p.Extensions {
+		merged[k] = v
+	}
+	var fields map[string]any
+	if err := jsonutil.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return jsonutil.Marshal(&merged)
+}
+
+// NewProblem 按错误码定义创建 Problem，title 使用定义中的默认文案
+func NewProblem(def errors.Definition) Problem {
+	return Problem{
+		Title:     def.Message,
+		Status:    def.Status,
+		Code:      def.Code,
+		Retryable: def.Retryable,
+	}
+}
+
+// AbortWithProblem 中止请求并写入 problem+json 响应，自动补充 type、instance 和 request ID
+func AbortWithProblem(c *gin.Context, p Problem) {
+	if p.Type == "" {
+		p.Type = "about:blank"
+	}
+	if p.Instance == "" {
+		p.Instance = c.Request.URL.Path
+	}
+	if p.RequestID == "" {
+		p.RequestID = GetRequestID(c)
+	}
+
+	data, err := jsonutil.Marshal(&p)
+	if err != nil {
+		c.AbortWithStatus(p.Status)
+		return
+	}
+	c.Abort()
+	c.Render(p.Status, render.Data{ContentType: ProblemContentType, Data: data})
+}
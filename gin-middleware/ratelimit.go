@@ -0,0 +1,217 @@
+/**
+  @author: 35840
+  @date: 2026/10/19
+  @desc: 按 key 限流，输出 RateLimit-* 响应头和 429 problem+json 响应
+**/
+
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huabingli/go-common/errors"
+	"github.com/huabingli/go-common/ratelimit"
+)
+
+// DefaultAPIKeyHeader 默认的 API key header
+const DefaultAPIKeyHeader = "X-API-Key"
+
+// KeyFunc 从请求中提取限流等使用的 key，返回空字符串表示不处理该请求
+type KeyFunc func(c *gin.Context) string
+
+// KeyByIP 按客户端 IP，受 gin 的 TrustedProxies 配置影响
+func KeyByIP() KeyFunc {
+	return func(c *gin.Context) string {
+		return "ip:" + c.ClientIP()
+	}
+}
+
+// KeyByHeader 按请求头的值，请求头为空时返回空字符串。
+// 请求头的值可能是令牌等敏感信息，以 SHA-256 摘要的形式出现在 key 中
+func KeyByHeader(name string) KeyFunc {
+	return func(c *gin.Context) string {
+		if v := c.GetHeader(name); v != "" {
+			return name + ":" + hashKeyValue(v)
+		}
+		return ""
+	}
+}
+
+// KeyByAPIKey 按 API key，header 为空时使用 DefaultAPIKeyHeader。
+// API key 以 SHA-256 摘要的形式出现在 key 中，不会原样保存到存储或日志
+func KeyByAPIKey(header string) KeyFunc {
+	if header == "" {
+		header = DefaultAPIKeyHeader
+	}
+	return func(c *gin.Context) string {
+		if v := c.GetHeader(header); v != "" {
+			return "apikey:" + hashKeyValue(v)
+		}
+		return ""
+	}
+}
+
+// KeyByRequestOrigin 按发起请求的源头：优先使用 traceparent 中的 trace-id，其次使用调用方传入的 request ID，
+// 用于限制同一个上游请求扇出或重试的次数。两者都没有时（包括 NewRequestIDMiddleware 生成的 request ID）返回空字符串，
+// 通常与 KeyFirst 组合使用，如 KeyFirst(KeyByRequestOrigin(), KeyByIP())
+func KeyByRequestOrigin() KeyFunc {
+	return func(c *gin.Context) string {
+		if traceID := parseTraceParent(c.GetHeader("traceparent")); traceID != "" {
+			return "trace:" + traceID
+		}
+		if c.GetBool(requestIDGeneratedContextKey) {
+			return ""
+		}
+		if requestID := GetRequestID(c); requestID != "" {
+			return "reqid:" + hashKeyValue(requestID)
+		}
+		return ""
+	}
+}
+
+// hashKeyValue 返回值的 SHA-256 摘要前 8 字节的十六进制，避免客户端传入的原始值出现在存储和日志中
+func hashKeyValue(v string) string {
+	sum := sha256.Sum256([]byte(v))
+	return hex.EncodeToString(sum[:8])
+}
+
+// KeyBySubject 按 DefaultSubject 获取的认证主体，未认证时返回空字符串
+func KeyBySubject() KeyFunc {
+	return func(c *gin.Context) string {
+		if sub, ok := DefaultSubject(c); ok && sub.ID != "" {
+			return "sub:" + sub.ID
+		}
+		return ""
+	}
+}
+
+// KeyFirst 依次尝试多个 KeyFunc，返回第一个非空的 key，如 KeyFirst(KeyByAPIKey(""), KeyByIP())
+func KeyFirst(fns ...KeyFunc) KeyFunc {
+	return func(c *gin.Context) string {
+		for _, fn := range fns {
+			if key := fn(c); key != "" {
+				return key
+			}
+		}
+		return ""
+	}
+}
+
+// RateLimitConfig 限流中间件配置
+type RateLimitConfig struct {
+	// Limiter 限流器，必填
+	Limiter ratelimit.Limiter
+	// Name 限流策略名称，作为存储 key 的前缀并输出到日志，多个限流中间件共用一个存储时需要区分，默认 "default"
+	Name string
+	// KeyFunc 限流的 key，默认 KeyByIP()，返回空字符串时不限流
+	KeyFunc KeyFunc
+	// Skip 返回 true 时不限流
+	Skip func(c *gin.Context) bool
+	// DisableHeaders 不输出 RateLimit-* 响应头，被拒绝时仍然输出 Retry-After
+	DisableHeaders bool
+	// FailClosed 存储出错时拒绝请求（503），默认放行
+	FailClosed bool
+	// DenyHandler 自定义被拒绝时的响应，需要调用 c.Abort，默认输出 429 problem+json
+	DenyHandler func(c *gin.Context, result ratelimit.Result)
+}
+
+// RateLimit 按客户端 IP 限流
+func RateLimit(limiter ratelimit.Limiter) gin.HandlerFunc {
+	return RateLimitWithConfig(RateLimitConfig{Limiter: limiter})
+}
+
+// RateLimitWithConfig 按配置创建限流中间件，决策结果以 "rateLimit" 分组附加到 GSlog 的访问日志
+func RateLimitWithConfig(cfg RateLimitConfig) gin.HandlerFunc {
+	if cfg.Limiter == nil {
+		panic("middleware: RateLimitConfig.Limiter 不能为空")
+	}
+	if cfg.Name == "" {
+		cfg.Name = "default"
+	}
+	if cfg.KeyFunc == nil {
+		cfg.KeyFunc = KeyByIP()
+	}
+	if cfg.DenyHandler == nil {
+		cfg.DenyHandler = func(c *gin.Context, _ ratelimit.Result) {
+			AbortWithProblem(c, NewProblem(errors.TooManyRequests))
+		}
+	}
+	policy := rateLimitPolicy(cfg.Limiter.Limit())
+
+	return func(c *gin.Context) {
+		if cfg.Skip != nil && cfg.Skip(c) {
+			c.Next()
+			return
+		}
+		key := cfg.KeyFunc(c)
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		result, err := cfg.Limiter.Allow(c.Request.Context(), cfg.Name+":"+key)
+		if err != nil {
+			slog.ErrorContext(
+				c.Request.Context(), "限流存储出错",
+				slog.String("policy", cfg.Name),
+				slog.Bool("failClosed", cfg.FailClosed),
+				slog.Any("err", err),
+			)
+			AddLogAttrs(c, slog.Group("rateLimit", slog.String("policy", cfg.Name), slog.String("error", err.Error())))
+			if cfg.FailClosed {
+				MarkNotExecuted(c)
+				AbortWithProblem(c, NewProblem(errors.ServiceUnavailable))
+				return
+			}
+			c.Next()
+			return
+		}
+
+		AddLogAttrs(
+			c, slog.Group(
+				"rateLimit",
+				slog.String("policy", cfg.Name),
+				slog.String("key", key),
+				slog.Bool("allowed", result.Allowed),
+				slog.Int("limit", result.Limit),
+				slog.Int("remaining", result.Remaining),
+			),
+		)
+
+		if !cfg.DisableHeaders {
+			c.Header("RateLimit-Limit", strconv.Itoa(result.Limit))
+			c.Header("RateLimit-Remaining", strconv.Itoa(max(result.Remaining, 0)))
+			c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.ResetAfter)))
+			c.Header("RateLimit-Policy", policy)
+		}
+		if !result.Allowed {
+			c.Header("Retry-After", strconv.Itoa(max(ceilSeconds(result.RetryAfter), 1)))
+			MarkNotExecuted(c)
+			cfg.DenyHandler(c, result)
+			return
+		}
+		c.Next()
+	}
+}
+
+// rateLimitPolicy 生成 RateLimit-Policy 响应头，如 "100;w=60;burst=20"
+func rateLimitPolicy(limit ratelimit.Limit) string {
+	policy := strconv.Itoa(limit.Rate) + ";w=" + strconv.Itoa(max(ceilSeconds(limit.Period), 1))
+	if limit.Burst > 0 && limit.Burst != limit.Rate {
+		policy += ";burst=" + strconv.Itoa(limit.Burst)
+	}
+	return policy
+}
+
+// ceilSeconds 向上取整的秒数
+func ceilSeconds(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+	return int((d + time.Second - 1) / time.Second)
+}
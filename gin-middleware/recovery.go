@@ -7,24 +7,60 @@
 package middleware
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"html/template"
 	"log/slog"
 	"net"
 	"net/http"
-	"net/http/httputil"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/huabingli/go-common"
+	cerrors "github.com/huabingli/go-common/errors"
 )
 
 type ErrorHandlerFunc func(c *gin.Context, err any)
 
+// RecoveryConfig Recovery 中间件配置
+type RecoveryConfig struct {
+	// Handler 自定义错误处理函数，为空时输出默认的 500 响应
+	Handler ErrorHandlerFunc
+	// Debug 默认响应中是否包含 panic 值和调用栈，仅建议在开发环境开启
+	Debug bool
+	// Reporters panic 事件上报器，同一指纹在去重窗口内只上报一次
+	Reporters []Reporter
+	// DedupWindow 去重窗口，默认 1 分钟，小于 0 时不去重
+	DedupWindow time.Duration
+	// HeaderPolicy 日志中请求头的脱敏策略，为空时使用 DefaultHeaderPolicy
+	HeaderPolicy *HeaderPolicy
+}
+
+// Recovery 捕获 panic 并记录日志，handle 为 nil 时输出默认的 500 响应
 func Recovery(handle ErrorHandlerFunc) gin.HandlerFunc {
+	return RecoveryWithConfig(RecoveryConfig{Handler: handle})
+}
+
+// RecoveryWithConfig 按配置创建 Recovery 中间件
+func RecoveryWithConfig(cfg RecoveryConfig) gin.HandlerFunc {
+	if cfg.DedupWindow == 0 {
+		cfg.DedupWindow = time.Minute
+	}
+	deduper := newPanicDeduper(cfg.DedupWindow)
+	if cfg.HeaderPolicy == nil {
+		cfg.HeaderPolicy = DefaultHeaderPolicy()
+	}
+
 	return func(c *gin.Context) {
 		defer func() {
 			if r := recover(); r != nil {
+				// 在 recover 处记录调用栈，此时 panic 现场的栈帧还在
+				stack := panicStack(cerrors.Callers(0))
+				now := time.Now()
+
 				var brokenPipe bool
 				var err error
 
@@ -45,16 +81,46 @@ func Recovery(handle ErrorHandlerFunc) gin.HandlerFunc {
 					err = errors.New(fmt.Sprint(r))
 				}
 
-				headersToStr := sanitizeRequestHeaders(c.Request)
 				ctx := c.Request.Context()
+				traceID := GetTraceID(c)
+				panicType := fmt.Sprintf("%T", r)
+				fingerprint := panicFingerprint(panicType, stack)
+				report, suppressed := deduper.observe(fingerprint, now)
 
-				slog.ErrorContext(
-					ctx, "服务器内部错误！",
+				attrs := []any{
 					slog.Any("err", err),
 					slog.String("method", c.Request.Method),
 					slog.String("url", c.Request.URL.String()),
-					slog.String("headers", headersToStr),
-				)
+					slog.String("host", c.Request.Host),
+					cfg.HeaderPolicy.Attr("headers", c.Request.Header),
+					slog.String("trace_id", traceID),
+					slog.String("fingerprint", fingerprint),
+				}
+				if report {
+					// 同一指纹在去重窗口内只输出一次调用栈
+					attrs = append(attrs, slog.Any("stack", stack), slog.Int("suppressed", suppressed))
+				} else {
+					attrs = append(attrs, slog.Bool("duplicate", true))
+				}
+				slog.ErrorContext(ctx, "服务器内部错误！", attrs...)
+
+				if report && len(cfg.Reporters) > 0 {
+					event := PanicEvent{
+						EventID:     common.GenerateRequestID(),
+						Fingerprint: fingerprint,
+						Time:        now,
+						Type:        panicType,
+						Value:       fmt.Sprint(r),
+						Stack:       stack,
+						Method:      c.Request.Method,
+						URL:         c.Request.URL.String(),
+						Route:       c.FullPath(),
+						RequestID:   GetRequestID(c),
+						TraceID:     traceID,
+						Suppressed:  suppressed,
+					}
+					reportPanic(context.WithoutCancel(ctx), cfg.Reporters, event)
+				}
 
 				if brokenPipe {
 					c.Error(err)
@@ -63,21 +129,95 @@ func Recovery(handle ErrorHandlerFunc) gin.HandlerFunc {
 				}
 
 				// 调用传入的错误处理函数
-				handle(c, r)
+				if cfg.Handler != nil {
+					cfg.Handler(c, r)
+					return
+				}
+				writeRecoveryResponse(c, r, stack, cfg.Debug)
 			}
 		}()
 		c.Next()
 	}
 }
 
-func sanitizeRequestHeaders(r *http.Request) string {
-	httpRequest, _ := httputil.DumpRequest(r, false)
-	headers := strings.Split(string(httpRequest), "\r\n")
-	for idx, header := range headers {
-		current := strings.SplitN(header, ":", 2)
-		if len(current) == 2 && strings.EqualFold(strings.TrimSpace(current[0]), "Authorization") {
-			headers[idx] = current[0] + ": *"
+// reportPanic 在后台调用各个上报器，上报失败只记录日志
+func reportPanic(ctx context.Context, reporters []Reporter, event PanicEvent) {
+	for _, reporter := range reporters {
+		reporter := reporter
+		common.SafeGo(
+			func() {
+				if err := reporter.Report(ctx, event); err != nil {
+					slog.WarnContext(ctx, "panic 事件上报失败", slog.Any("err", err), slog.String("fingerprint", event.Fingerprint))
+				}
+			}, func(r interface{}) {
+				slog.ErrorContext(ctx, "panic 事件上报器发生 panic", slog.Any("panic", r))
+			},
+		)
+	}
+}
+
+// recoveryHTML 浏览器访问时输出的错误页面
+var recoveryHTML = template.Must(
+	template.New("recovery").Parse(
+		`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Title}}</title></head>
+<body>
+<h1>{{.Status}} {{.Title}}</h1>
+<p>Request ID: {{.RequestID}}<br>Trace ID: {{.TraceID}}</p>
+{{- with .Extensions}}
+<pre>{{index . "panic"}}
+{{range index . "stack"}}
+{{.Function}}
+	{{.File}}:{{.Line}}{{end}}</pre>
+{{- end}}
+</body>
+</html>
+`,
+	),
+)
+
+// writeRecoveryResponse 输出默认的 500 响应，根据 Accept 选择 problem+json、纯文本或 HTML
+func writeRecoveryResponse(c *gin.Context, r any, stack []cerrors.Frame, debug bool) {
+	// 已经开始输出响应时无法再修改状态码
+	if c.Writer.Written() {
+		c.Abort()
+		return
+	}
+
+	p := NewProblem(cerrors.Internal)
+	p.Type = "about:blank"
+	p.Instance = c.Request.URL.Path
+	p.RequestID = GetRequestID(c)
+	p.TraceID = GetTraceID(c)
+	if debug {
+		p.Extensions = map[string]any{
+			"panic": fmt.Sprint(r),
+			"stack": stack,
+		}
+	}
+
+	switch c.NegotiateFormat(ProblemContentType, gin.MIMEJSON, gin.MIMEHTML, gin.MIMEPlain) {
+	case gin.MIMEHTML:
+		var sb strings.Builder
+		if err := recoveryHTML.Execute(&sb, p); err != nil {
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		c.Abort()
+		c.Data(http.StatusInternalServerError, "text/html; charset=utf-8", []byte(sb.String()))
+	case gin.MIMEPlain:
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "%d %s\nrequest_id: %s\ntrace_id: %s\n", p.Status, p.Title, p.RequestID, p.TraceID)
+		if debug {
+			fmt.Fprintf(&sb, "\npanic: %v\n", r)
+			for _, f := range stack {
+				fmt.Fprintf(&sb, "\n%s\n\t%s:%d", f.Function, f.File, f.Line)
+			}
 		}
+		c.Abort()
+		c.Data(http.StatusInternalServerError, "text/plain; charset=utf-8", []byte(sb.String()))
+	default:
+		AbortWithProblem(c, p)
 	}
-	return strings.Join(headers, "\r\n")
 }
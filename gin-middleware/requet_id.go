@@ -8,15 +8,28 @@ package middleware
 
 import (
 	"context"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/huabingli/go-common"
 )
 
+// DefaultRequestIDHeader 默认的 request ID header
+const DefaultRequestIDHeader = "X-Request-ID"
+
+// requestIDContextKey gin.Context 中保存 request ID 的 key，与 header 名称无关，便于其他中间件读取
+const requestIDContextKey = "_go-common/requestID"
+
+// requestIDGeneratedContextKey gin.Context 中标记 request ID 由中间件生成而不是调用方传入
+const requestIDGeneratedContextKey = "_go-common/requestIDGenerated"
+
+// traceIDContextKey gin.Context 中保存 trace ID 的 key
+const traceIDContextKey = "_go-common/traceID"
+
 // NewRequestIDMiddleware 创建带自定义 header key 的 RequestID 中间件
 func NewRequestIDMiddleware(headerKeys ...string) gin.HandlerFunc {
 
-	headerKey := "X-Request-ID"
+	headerKey := DefaultRequestIDHeader
 	if len(headerKeys) > 0 && headerKeys[0] != "" {
 		headerKey = headerKeys[0]
 	}
@@ -30,6 +43,7 @@ func NewRequestIDMiddleware(headerKeys ...string) gin.HandlerFunc {
 		if requestID == "" {
 			requestID = common.GenerateRequestID()
 			c.Request.Header.Set(headerKey, requestID)
+			c.Set(requestIDGeneratedContextKey, true)
 		}
 
 		c.Header(headerKey, requestID)
@@ -40,7 +54,44 @@ func NewRequestIDMiddleware(headerKeys ...string) gin.HandlerFunc {
 
 		// 如果你有需要，也可以设置到 gin.Context：
 		c.Set(headerKey, requestID)
+		c.Set(requestIDContextKey, requestID)
 
 		c.Next()
 	}
 }
+
+// GetRequestID 获取 NewRequestIDMiddleware 设置的 request ID，未使用该中间件时从默认 header 读取
+func GetRequestID(c *gin.Context) string {
+	if requestID := c.GetString(requestIDContextKey); requestID != "" {
+		return requestID
+	}
+	return c.GetHeader(DefaultRequestIDHeader)
+}
+
+// GetTraceID 获取链路追踪 ID：优先使用 W3C traceparent header 中的 trace-id，
+// 没有时生成一个，同一个请求内多次调用返回相同的值
+func GetTraceID(c *gin.Context) string {
+	if traceID := c.GetString(traceIDContextKey); traceID != "" {
+		return traceID
+	}
+	traceID := parseTraceParent(c.GetHeader("traceparent"))
+	if traceID == "" {
+		traceID = common.GenerateRequestID()
+	}
+	c.Set(traceIDContextKey, traceID)
+	return traceID
+}
+
+// parseTraceParent 解析 "version-traceid-parentid-flags" 格式的 traceparent，返回 trace-id
+func parseTraceParent(header string) string {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 || len(parts[1]) != 32 || parts[1] == strings.Repeat("0", 32) {
+		return ""
+	}
+	for _, r := range parts[1] {
+		if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'f') {
+			return ""
+		}
+	}
+	return parts[1]
+}
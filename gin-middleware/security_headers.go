@@ -0,0 +1,369 @@
+/**
+  @author: 35840
+  @date: 2026/10/19
+  @desc: 安全响应头：CSP（支持 nonce 和 report-only）、HSTS、Referrer-Policy、Permissions-Policy、COOP/COEP 等
+**/
+
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huabingli/go-common/jsonutil"
+)
+
+// cspNonceContextKey gin.Context 中保存 CSP nonce 的 key
+const cspNonceContextKey = "_go-common/cspNonce"
+
+// CSPNonce 是 CSP 指令中的占位符，每个请求替换为新生成的 nonce，如 ScriptSrc: []string{CSPSelf, CSPNonce}
+const CSPNonce = "'nonce'"
+
+// CSP 常用的来源关键字
+const (
+	CSPSelf          = "'self'"
+	CSPNone          = "'none'"
+	CSPUnsafeInline  = "'unsafe-inline'"
+	CSPUnsafeEval    = "'unsafe-eval'"
+	CSPStrictDynamic = "'strict-dynamic'"
+)
+
+// CSPPolicy 结构化的 Content-Security-Policy，空的指令不输出
+type CSPPolicy struct {
+	DefaultSrc     []string
+	ScriptSrc      []string
+	StyleSrc       []string
+	ImgSrc         []string
+	FontSrc        []string
+	ConnectSrc     []string
+	MediaSrc       []string
+	ObjectSrc      []string
+	FrameSrc       []string
+	WorkerSrc      []string
+	ManifestSrc    []string
+	ChildSrc       []string
+	FrameAncestors []string
+	FormAction     []string
+	BaseURI        []string
+	// Sandbox 非 nil 时输出 sandbox 指令，空切片表示应用所有限制
+	Sandbox []string
+	// UpgradeInsecureRequests 输出 upgrade-insecure-requests
+	UpgradeInsecureRequests bool
+	// ReportURI 违规报告地址，如 CSPReportHandler 所在的路径
+	ReportURI string
+	// Extra 其他指令，如 {"require-trusted-types-for": {"'script'"}}
+	Extra map[string][]string
+}
+
+// DefaultCSPPolicy 适合后台管理页面的严格策略：只允许同源资源，脚本和样式需要 nonce，禁止被嵌入
+func DefaultCSPPolicy() CSPPolicy {
+	return CSPPolicy{
+		DefaultSrc:     []string{CSPSelf},
+		ScriptSrc:      []string{CSPSelf, CSPNonce},
+		StyleSrc:       []string{CSPSelf, CSPNonce},
+		ImgSrc:         []string{CSPSelf, "data:"},
+		ObjectSrc:      []string{CSPNone},
+		FrameAncestors: []string{CSPNone},
+		FormAction:     []string{CSPSelf},
+		BaseURI:        []string{CSPSelf},
+	}
+}
+
+// String 生成指令字符串，nonce 占位符保留为 CSPNonce
+func (p CSPPolicy) String() string {
+	directives := []struct {
+		name    string
+		sources []string
+	}{
+		{"default-src", p.DefaultSrc},
+		{"script-src", p.ScriptSrc},
+		{"style-src", p.StyleSrc},
+		{"img-src", p.ImgSrc},
+		{"font-src", p.FontSrc},
+		{"connect-src", p.ConnectSrc},
+		{"media-src", p.MediaSrc},
+		{"object-src", p.ObjectSrc},
+		{"frame-src", p.FrameSrc},
+		{"worker-src", p.WorkerSrc},
+		{"manifest-src", p.ManifestSrc},
+		{"child-src", p.ChildSrc},
+		{"frame-ancestors", p.FrameAncestors},
+		{"form-action", p.FormAction},
+		{"base-uri", p.BaseURI},
+	}
+	var parts []string
+	for _, d := range directives {
+		if len(d.sources) > 0 {
+			parts = append(parts, d.name+" "+strings.Join(d.sources, " "))
+		}
+	}
+	if p.Sandbox != nil {
+		parts = append(parts, strings.TrimSpace("sandbox "+strings.Join(p.Sandbox, " ")))
+	}
+	if p.UpgradeInsecureRequests {
+		parts = append(parts, "upgrade-insecure-requests")
+	}
+	extra := make([]string, 0, len(p.Extra))
+	for name := range p.Extra {
+		extra = append(extra, name)
+	}
+	sort.Strings(extra)
+	for _, name := range extra {
+		parts = append(parts, strings.TrimSpace(name+" "+strings.Join(p.Extra[name], " ")))
+	}
+	if p.ReportURI != "" {
+		parts = append(parts, "report-uri "+p.ReportURI)
+	}
+	return strings.Join(parts, "; ")
+}
+
+// HSTSConfig Strict-Transport-Security 配置
+type HSTSConfig struct {
+	// MaxAge 默认 180 天
+	MaxAge            time.Duration
+	IncludeSubDomains bool
+	Preload           bool
+}
+
+// String 生成响应头的值
+func (h HSTSConfig) String() string {
+	if h.MaxAge <= 0 {
+		h.MaxAge = 180 * 24 * time.Hour
+	}
+	value := "max-age=" + strconv.FormatInt(int64(h.MaxAge/time.Second), 10)
+	if h.IncludeSubDomains {
+		value += "; includeSubDomains"
+	}
+	if h.Preload {
+		value += "; preload"
+	}
+	return value
+}
+
+// SecurityHeadersConfig 安全响应头配置，字符串为空的响应头不输出
+type SecurityHeadersConfig struct {
+	// CSP 非空时输出 Content-Security-Policy，包含 CSPNonce 时每个请求生成新的 nonce
+	CSP *CSPPolicy
+	// CSPReportOnly 输出 Content-Security-Policy-Report-Only，只报告不拦截，用于上线前观察
+	CSPReportOnly bool
+	// HSTS 非空时在 HTTPS 请求（包括 X-Forwarded-Proto: https）上输出 Strict-Transport-Security
+	HSTS *HSTSConfig
+	// FrameOptions X-Frame-Options，如 "DENY"、"SAMEORIGIN"
+	FrameOptions string
+	// ContentTypeNosniff 输出 X-Content-Type-Options: nosniff
+	ContentTypeNosniff bool
+	// ReferrerPolicy 如 "strict-origin-when-cross-origin"
+	ReferrerPolicy string
+	// PermissionsPolicy 按功能名列出允许的来源，如 {"camera": {}, "geolocation": {"self"}}，空切片表示禁用
+	PermissionsPolicy map[string][]string
+	// CrossOriginOpenerPolicy 如 "same-origin"
+	CrossOriginOpenerPolicy string
+	// CrossOriginEmbedderPolicy 如 "require-corp"、"credentialless"
+	CrossOriginEmbedderPolicy string
+	// CrossOriginResourcePolicy 如 "same-origin"
+	CrossOriginResourcePolicy string
+}
+
+// DefaultSecurityHeadersConfig 适合后台管理页面的默认配置
+func DefaultSecurityHeadersConfig() SecurityHeadersConfig {
+	csp := DefaultCSPPolicy()
+	return SecurityHeadersConfig{
+		CSP:                       &csp,
+		HSTS:                      &HSTSConfig{IncludeSubDomains: true},
+		FrameOptions:              "DENY",
+		ContentTypeNosniff:        true,
+		ReferrerPolicy:            "strict-origin-when-cross-origin",
+		PermissionsPolicy:         map[string][]string{"camera": {}, "microphone": {}, "geolocation": {}, "payment": {}},
+		CrossOriginOpenerPolicy:   "same-origin",
+		CrossOriginResourcePolicy: "same-origin",
+	}
+}
+
+// SecurityHeaders 使用默认配置创建安全响应头中间件
+func SecurityHeaders() gin.HandlerFunc {
+	return SecurityHeadersWithConfig(DefaultSecurityHeadersConfig())
+}
+
+// SecurityHeadersWithConfig 按配置创建安全响应头中间件，模板中通过 GetCSPNonce 获取本次请求的 nonce
+func SecurityHeadersWithConfig(cfg SecurityHeadersConfig) gin.HandlerFunc {
+	static := make(http.Header)
+	setIf := func(name, value string) {
+		if value != "" {
+			static.Set(name, value)
+		}
+	}
+	setIf("X-Frame-Options", cfg.FrameOptions)
+	if cfg.ContentTypeNosniff {
+		static.Set("X-Content-Type-Options", "nosniff")
+	}
+	setIf("Referrer-Policy", cfg.ReferrerPolicy)
+	setIf("Permissions-Policy", permissionsPolicy(cfg.PermissionsPolicy))
+	setIf("Cross-Origin-Opener-Policy", cfg.CrossOriginOpenerPolicy)
+	setIf("Cross-Origin-Embedder-Policy", cfg.CrossOriginEmbedderPolicy)
+	setIf("Cross-Origin-Resource-Policy", cfg.CrossOriginResourcePolicy)
+
+	var hsts string
+	if cfg.HSTS != nil {
+		hsts = cfg.HSTS.String()
+	}
+
+	var csp, cspHeader string
+	var needNonce bool
+	if cfg.CSP != nil {
+		csp = cfg.CSP.String()
+		needNonce = strings.Contains(csp, CSPNonce)
+		cspHeader = "Content-Security-Policy"
+		if cfg.CSPReportOnly {
+			cspHeader = "Content-Security-Policy-Report-Only"
+		}
+	}
+
+	return func(c *gin.Context) {
+		h := c.Writer.Header()
+		for name, values := range static {
+			// 复制一份，后续的 Header().Add 等修改不能影响其他请求
+			h[name] = slices.Clone(values)
+		}
+		if hsts != "" && isHTTPS(c) {
+			h.Set("Strict-Transport-Security", hsts)
+		}
+		if csp != "" {
+			value := csp
+			if needNonce {
+				nonce := newCSPNonce()
+				c.Set(cspNonceContextKey, nonce)
+				value = strings.ReplaceAll(csp, CSPNonce, "'nonce-"+nonce+"'")
+			}
+			h.Set(cspHeader, value)
+		}
+		c.Next()
+	}
+}
+
+// GetCSPNonce 获取本次请求的 CSP nonce，用于 <script nonce="{{ .nonce }}">，策略中没有 CSPNonce 时返回空字符串
+func GetCSPNonce(c *gin.Context) string {
+	return c.GetString(cspNonceContextKey)
+}
+
+// newCSPNonce 生成 128 位随机 nonce
+func newCSPNonce() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+// permissionsPolicy 生成 Permissions-Policy，如 camera=(), geolocation=(self "https://maps.example.com")
+func permissionsPolicy(features map[string][]string) string {
+	names := make([]string, 0, len(features))
+	for name := range features {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		allow := make([]string, 0, len(features[name]))
+		for _, origin := range features[name] {
+			if origin == "self" || origin == "*" {
+				allow = append(allow, origin)
+			} else {
+				allow = append(allow, strconv.Quote(origin))
+			}
+		}
+		parts = append(parts, name+"=("+strings.Join(allow, " ")+")")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// isHTTPS 判断请求是否通过 HTTPS 访问，包括 TLS 终止在反向代理的情况
+func isHTTPS(c *gin.Context) bool {
+	return c.Request.TLS != nil || strings.EqualFold(c.GetHeader("X-Forwarded-Proto"), "https")
+}
+
+// CSPReportHandler 接收浏览器上报的 CSP 违规报告并通过 slog 记录，
+// 支持 report-uri 的 application/csp-report 和 Reporting API 的 application/reports+json，请求体最大 64KB
+func CSPReportHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, 64<<10))
+		if err != nil || len(body) == 0 {
+			c.Status(http.StatusBadRequest)
+			return
+		}
+		for _, report := range parseCSPReports(body) {
+			slog.WarnContext(
+				c.Request.Context(), "CSP 违规",
+				slog.String("documentURI", report.DocumentURI),
+				slog.String("violatedDirective", report.ViolatedDirective),
+				slog.String("effectiveDirective", report.EffectiveDirective),
+				slog.String("blockedURI", report.BlockedURI),
+				slog.String("sourceFile", report.SourceFile),
+				slog.Int("lineNumber", report.LineNumber),
+				slog.String("disposition", report.Disposition),
+				slog.String("userAgent", c.Request.UserAgent()),
+			)
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// cspReport CSP 违规报告中需要的字段
+type cspReport struct {
+	DocumentURI        string `json:"document-uri"`
+	ViolatedDirective  string `json:"violated-directive"`
+	EffectiveDirective string `json:"effective-directive"`
+	BlockedURI         string `json:"blocked-uri"`
+	SourceFile         string `json:"source-file"`
+	LineNumber         int    `json:"line-number"`
+	Disposition        string `json:"disposition"`
+}
+
+// parseCSPReports 解析两种格式的报告：{"csp-report": {...}} 和 [{"type": "csp-violation", "body": {...}}]
+func parseCSPReports(body []byte) []cspReport {
+	var legacy struct {
+		Report *cspReport `json:"csp-report"`
+	}
+	if err := jsonutil.Unmarshal(body, &legacy); err == nil && legacy.Report != nil {
+		return []cspReport{*legacy.Report}
+	}
+
+	var batch []struct {
+		Type string `json:"type"`
+		Body struct {
+			DocumentURL        string `json:"documentURL"`
+			EffectiveDirective string `json:"effectiveDirective"`
+			BlockedURL         string `json:"blockedURL"`
+			SourceFile         string `json:"sourceFile"`
+			LineNumber         int    `json:"lineNumber"`
+			Disposition        string `json:"disposition"`
+		} `json:"body"`
+	}
+	if err := jsonutil.Unmarshal(body, &batch); err != nil {
+		return nil
+	}
+	reports := make([]cspReport, 0, len(batch))
+	for _, r := range batch {
+		if r.Type != "csp-violation" {
+			continue
+		}
+		reports = append(
+			reports, cspReport{
+				DocumentURI:        r.Body.DocumentURL,
+				ViolatedDirective:  r.Body.EffectiveDirective,
+				EffectiveDirective: r.Body.EffectiveDirective,
+				BlockedURI:         r.Body.BlockedURL,
+				SourceFile:         r.Body.SourceFile,
+				LineNumber:         r.Body.LineNumber,
+				Disposition:        r.Body.Disposition,
+			},
+		)
+	}
+	return reports
+}
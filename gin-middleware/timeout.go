@@ -0,0 +1,306 @@
+/**
+  @author: 35840
+  @date: 2026/10/19
+  @desc: 请求超时：为 c.Request.Context() 设置截止时间，超时后输出 504 problem+json 响应
+**/
+
+package middleware
+
+import (
+	"bufio"
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huabingli/go-common"
+	"github.com/huabingli/go-common/errors"
+	"github.com/huabingli/go-common/jsonutil"
+)
+
+// DefaultTimeoutHeader 客户端指定超时时间的默认 header
+const DefaultTimeoutHeader = "X-Request-Timeout"
+
+// TimeoutConfig 请求超时中间件配置
+type TimeoutConfig struct {
+	// Timeout 默认的超时时间，默认 30 秒
+	Timeout time.Duration
+	// RouteTimeouts 按路由模板（c.FullPath()）覆盖超时时间
+	RouteTimeouts map[string]time.Duration
+	// Header 允许客户端通过该 header 指定超时时间，如 "X-Request-Timeout: 2.5" 或 "1500ms"，
+	// 纯数字的单位为秒，为空时不读取
+	Header string
+	// MaxTimeout 客户端指定的超时时间上限，默认为路由的超时时间，即客户端只能缩短超时时间
+	MaxTimeout time.Duration
+	// Skip 返回 true 时不设置超时，如 WebSocket、SSE 等长连接
+	Skip func(c *gin.Context) bool
+}
+
+// Timeout 使用固定的超时时间
+func Timeout(timeout time.Duration) gin.HandlerFunc {
+	return TimeoutWithConfig(TimeoutConfig{Timeout: timeout})
+}
+
+// TimeoutWithConfig 按配置创建请求超时中间件。
+// 处理器仍在当前 goroutine 中执行，需要自行响应 c.Request.Context() 的取消；
+// 截止时间到达时如果还没有写出任何响应，由中间件输出 504，之后处理器的写入会被丢弃并记录日志
+func TimeoutWithConfig(cfg TimeoutConfig) gin.HandlerFunc {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+
+	return func(c *gin.Context) {
+		if cfg.Skip != nil && cfg.Skip(c) {
+			c.Next()
+			return
+		}
+
+		timeout, source := cfg.timeout(c)
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		tw := &timeoutWriter{
+			ResponseWriter: c.Writer,
+			ctx:            ctx,
+			header:         make(http.Header),
+			status:         http.StatusOK,
+			instance:       c.Request.URL.Path,
+			requestID:      GetRequestID(c),
+		}
+		c.Writer = tw
+		stop := context.AfterFunc(
+			ctx, func() {
+				if ctx.Err() == context.DeadlineExceeded {
+					tw.timeout()
+				}
+			},
+		)
+
+		c.Next()
+
+		stop()
+		timedOut, discarded := tw.finish()
+		c.Writer = tw.ResponseWriter
+
+		attrs := []any{slog.Duration("timeout", timeout), slog.String("source", source)}
+		if timedOut {
+			attrs = append(attrs, slog.Bool("timedOut", true))
+			c.Abort()
+		}
+		AddLogAttrs(c, slog.Group("deadline", attrs...))
+		if discarded > 0 {
+			slog.WarnContext(
+				c.Request.Context(), "请求超时后丢弃了处理器的响应",
+				slog.String("path", c.Request.URL.Path),
+				slog.Duration("timeout", timeout),
+				slog.Int("discardedBytes", discarded),
+			)
+		}
+	}
+}
+
+// timeout 按 header、路由、全局的顺序确定超时时间，返回超时时间和来源
+func (cfg *TimeoutConfig) timeout(c *gin.Context) (time.Duration, string) {
+	timeout, source := cfg.Timeout, "default"
+	if d, ok := cfg.RouteTimeouts[c.FullPath()]; ok && d > 0 {
+		timeout, source = d, "route"
+	}
+	if cfg.Header == "" {
+		return timeout, source
+	}
+	value := c.GetHeader(cfg.Header)
+	if value == "" {
+		return timeout, source
+	}
+	d, err := parseTimeoutHeader(value)
+	if err != nil || d <= 0 {
+		return timeout, source
+	}
+	limit := cfg.MaxTimeout
+	if limit <= 0 {
+		limit = timeout
+	}
+	return min(d, limit), "header"
+}
+
+// parseTimeoutHeader 解析客户端指定的超时时间，纯数字的单位为秒
+func parseTimeoutHeader(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+	return common.ParseDuration(value)
+}
+
+// timeoutWriter 使用独立的 header map，所有写入都在锁内进行：
+// 截止时间到达时由定时器 goroutine 写出 504，处理器之后的写入被丢弃，避免两个 goroutine 同时写 ResponseWriter
+type timeoutWriter struct {
+	gin.ResponseWriter
+	ctx context.Context
+
+	mu        sync.Mutex
+	header    http.Header
+	status    int
+	committed bool // 已经把响应头写到了 ResponseWriter
+	timedOut  bool // 已经输出了 504
+	finished  bool // 中间件已经返回，定时器不能再写
+	discarded int
+
+	instance  string
+	requestID string
+}
+
+func (w *timeoutWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *timeoutWriter) WriteHeader(code int) {
+	if code <= 0 {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.committed && !w.timedOut {
+		w.status = code
+	}
+}
+
+func (w *timeoutWriter) WriteHeaderNow() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.expired() {
+		w.commit()
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *timeoutWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.expired() {
+		w.discarded += len(p)
+		return 0, http.ErrHandlerTimeout
+	}
+	w.commit()
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *timeoutWriter) WriteString(s string) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.expired() {
+		w.discarded += len(s)
+		return 0, http.ErrHandlerTimeout
+	}
+	w.commit()
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *timeoutWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.expired() {
+		w.commit()
+		w.ResponseWriter.Flush()
+	}
+}
+
+func (w *timeoutWriter) Status() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.committed || w.timedOut {
+		return w.ResponseWriter.Status()
+	}
+	return w.status
+}
+
+func (w *timeoutWriter) Written() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.committed || w.timedOut
+}
+
+func (w *timeoutWriter) Size() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.ResponseWriter.Size()
+}
+
+// Hijack 接管连接后不再输出超时响应
+func (w *timeoutWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut {
+		return nil, nil, http.ErrHandlerTimeout
+	}
+	w.finished = true
+	return w.ResponseWriter.Hijack()
+}
+
+// commit 第一次写出时把处理器设置的响应头和状态码复制到 ResponseWriter，调用方需要持有锁
+func (w *timeoutWriter) commit() {
+	if w.committed {
+		return
+	}
+	w.committed = true
+	dst := w.ResponseWriter.Header()
+	for k, v := range w.header {
+		dst[k] = v
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+}
+
+// expired 判断是否已经超时，截止时间已到但定时器还没有执行时立即输出 504，
+// 保证截止时间之后的第一次写入不会与定时器竞争。调用方需要持有锁
+func (w *timeoutWriter) expired() bool {
+	if !w.timedOut && !w.committed && w.ctx.Err() == context.DeadlineExceeded {
+		w.writeTimeout()
+	}
+	return w.timedOut
+}
+
+// timeout 截止时间到达时由定时器调用
+func (w *timeoutWriter) timeout() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.committed && !w.finished && !w.timedOut {
+		w.writeTimeout()
+	}
+}
+
+// writeTimeout 输出 504 problem+json 响应，调用方需要持有锁
+func (w *timeoutWriter) writeTimeout() {
+	w.timedOut = true
+
+	p := NewProblem(errors.GatewayTimeout)
+	p.Type = "about:blank"
+	p.Instance = w.instance
+	p.RequestID = w.requestID
+	data, err := jsonutil.Marshal(&p)
+	if err != nil {
+		w.ResponseWriter.WriteHeader(p.Status)
+		w.ResponseWriter.WriteHeaderNow()
+		return
+	}
+	w.ResponseWriter.Header().Set("Content-Type", ProblemContentType)
+	w.ResponseWriter.WriteHeader(p.Status)
+	_, _ = w.ResponseWriter.Write(data)
+}
+
+// finish 中间件返回前调用，之后定时器不会再写入，返回是否超时以及丢弃的字节数。
+// 处理器只设置了响应头和状态码而没有写出时，复制到 ResponseWriter，由 gin 在最后写出
+func (w *timeoutWriter) finish() (bool, int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.expired() {
+		w.commit()
+	}
+	w.finished = true
+	return w.timedOut, w.discarded
+}
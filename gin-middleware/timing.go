@@ -0,0 +1,122 @@
+/**
+  @author: 35840
+  @date: 2026/10/19
+  @desc: GSlog 的分阶段计时：Server-Timing 响应头和慢请求检测
+**/
+
+package middleware
+
+import (
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huabingli/go-common"
+)
+
+// TimingConfig 分阶段计时配置，处理器通过 common.StartPhase 记录阶段耗时
+type TimingConfig struct {
+	// ServerTiming 是否输出 Server-Timing 响应头，只包含响应头写出之前结束的阶段
+	ServerTiming bool
+	// SlowThreshold 慢请求阈值，超过时日志级别至少为 Warn，为 0 时不检测
+	SlowThreshold time.Duration
+	// RouteThresholds 按路由模板（c.FullPath()）覆盖慢请求阈值
+	RouteThresholds map[string]time.Duration
+}
+
+// threshold 返回路由对应的慢请求阈值
+func (cfg *TimingConfig) threshold(route string) time.Duration {
+	if d, ok := cfg.RouteThresholds[route]; ok {
+		return d
+	}
+	return cfg.SlowThreshold
+}
+
+// timingAttrs 输出各阶段耗时（毫秒）以及是否为慢请求
+func timingAttrs(timing *common.RequestTiming, slow bool, threshold time.Duration) []slog.Attr {
+	var attrs []slog.Attr
+	if phases := timing.Phases(); len(phases) > 0 {
+		phaseAttrs := make([]slog.Attr, 0, len(phases))
+		for _, p := range phases {
+			phaseAttrs = append(phaseAttrs, slog.Float64(p.Name, durationMillis(p.Duration)))
+		}
+		attrs = append(attrs, slog.Attr{Key: "timing", Value: slog.GroupValue(phaseAttrs...)})
+	}
+	if slow {
+		attrs = append(attrs, slog.Bool("slow", true), slog.String("slowThreshold", threshold.String()))
+	}
+	return attrs
+}
+
+// serverTimingHeader 生成 Server-Timing 响应头，名称不是合法 token 时替换为 "_" 并通过 desc 保留原名
+func serverTimingHeader(timing *common.RequestTiming) string {
+	phases := timing.Phases()
+	parts := make([]string, 0, len(phases)+1)
+	for _, p := range phases {
+		name := serverTimingName(p.Name)
+		part := name + ";dur=" + strconv.FormatFloat(durationMillis(p.Duration), 'f', -1, 64)
+		if name != p.Name {
+			part += `;desc="` + strings.ReplaceAll(p.Name, `"`, `'`) + `"`
+		}
+		parts = append(parts, part)
+	}
+	parts = append(parts, "total;dur="+strconv.FormatFloat(durationMillis(time.Since(timing.Start())), 'f', -1, 64))
+	return strings.Join(parts, ", ")
+}
+
+// serverTimingName 将阶段名称转换为合法的 token
+func serverTimingName(name string) string {
+	return strings.Map(
+		func(r rune) rune {
+			if r > 0x7e || r <= 0x20 || strings.ContainsRune(`"(),/:;<=>?@[\]{}`, r) {
+				return '_'
+			}
+			return r
+		}, name,
+	)
+}
+
+// durationMillis 转换为保留 3 位小数的毫秒数
+func durationMillis(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// timingWriter 在响应头真正写出之前补充 Server-Timing，gin 的 WriteHeader 只记录状态码，不需要处理
+type timingWriter struct {
+	gin.ResponseWriter
+	timing  *common.RequestTiming
+	written bool
+}
+
+// setHeader 只在第一次写出响应头之前设置一次
+func (w *timingWriter) setHeader() {
+	if w.written {
+		return
+	}
+	w.written = true
+	if !w.ResponseWriter.Written() {
+		w.Header().Set("Server-Timing", serverTimingHeader(w.timing))
+	}
+}
+
+func (w *timingWriter) WriteHeaderNow() {
+	w.setHeader()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *timingWriter) Write(p []byte) (int, error) {
+	w.setHeader()
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *timingWriter) WriteString(s string) (int, error) {
+	w.setHeader()
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *timingWriter) Flush() {
+	w.setHeader()
+	w.ResponseWriter.Flush()
+}
@@ -2,6 +2,7 @@ package common
 
 import (
 	"crypto/md5"
+	"crypto/sha256"
 	"encoding/hex"
 
 	"golang.org/x/crypto/bcrypt"
@@ -39,3 +40,15 @@ func MD5V(str []byte, b ...byte) string {
 	h.Write(str)
 	return hex.EncodeToString(h.Sum(b))
 }
+
+// SHA256V
+//
+//	@Description: sha256 摘要，适合 API key 等高熵密钥的存储，密码请使用 BcryptHash
+//	@param str []byte
+//	@param b byte
+//	@return string
+func SHA256V(str []byte, b ...byte) string {
+	h := sha256.New()
+	h.Write(str)
+	return hex.EncodeToString(h.Sum(b))
+}
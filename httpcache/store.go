@@ -0,0 +1,192 @@
+package httpcache
+
+import (
+	"container/list"
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Entry 一条缓存的响应
+type Entry struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       []byte      `json:"body,omitempty"`
+	// Tags 条目的标签，用于按标签失效
+	Tags      []string  `json:"tags,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	// FreshUntil 之前为新鲜的响应，之后到过期之前为 stale，可以先返回再后台刷新
+	FreshUntil time.Time `json:"freshUntil"`
+}
+
+// Fresh 判断条目在 now 时是否新鲜
+func (e *Entry) Fresh(now time.Time) bool {
+	return now.Before(e.FreshUntil)
+}
+
+// Size 条目占用的大致字节数
+func (e *Entry) Size() int {
+	size := len(e.Body)
+	for k, values := range e.Header {
+		size += len(k)
+		for _, v := range values {
+			size += len(v)
+		}
+	}
+	for _, tag := range e.Tags {
+		size += len(tag)
+	}
+	return size
+}
+
+// Store 响应缓存存储
+type Store interface {
+	// Get 获取条目，不存在或已过期时返回 false
+	Get(ctx context.Context, key string) (*Entry, bool, error)
+	// Set 保存条目，ttl 为条目的总有效期（新鲜时间加上 stale 时间）
+	Set(ctx context.Context, key string, entry *Entry, ttl time.Duration) error
+	// Delete 删除条目
+	Delete(ctx context.Context, key string) error
+	// InvalidateTags 删除带有任一标签的条目，返回删除的条目数
+	InvalidateTags(ctx context.Context, tags ...string) (int, error)
+}
+
+// MemoryStoreConfig 内存存储配置
+type MemoryStoreConfig struct {
+	// MaxEntries 最多保存的条目数，默认 10000
+	MaxEntries int
+	// MaxBytes 所有条目的最大字节数（按 Entry.Size 计算），默认 64MB
+	MaxBytes int
+}
+
+// MemoryStore 内存中的 LRU 存储，超出条目数或字节数时淘汰最久未使用的条目，过期的条目在读取或淘汰时删除
+type MemoryStore struct {
+	mu      sync.Mutex
+	cfg     MemoryStoreConfig
+	lru     *list.List // 元素为 *memoryEntry，最近使用的在前
+	entries map[string]*list.Element
+	tags    map[string]map[string]struct{} // 标签 -> key 集合
+	bytes   int
+}
+
+type memoryEntry struct {
+	key     string
+	entry   *Entry
+	size    int
+	expires time.Time
+}
+
+// NewMemoryStore 使用默认配置创建内存存储
+func NewMemoryStore() *MemoryStore {
+	return NewMemoryStoreWithConfig(MemoryStoreConfig{})
+}
+
+// NewMemoryStoreWithConfig 按配置创建内存存储
+func NewMemoryStoreWithConfig(cfg MemoryStoreConfig) *MemoryStore {
+	if cfg.MaxEntries <= 0 {
+		cfg.MaxEntries = 10000
+	}
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = 64 << 20
+	}
+	return &MemoryStore{
+		cfg:     cfg,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+		tags:    make(map[string]map[string]struct{}),
+	}
+}
+
+// Get 获取条目并标记为最近使用
+func (s *MemoryStore) Get(_ context.Context, key string) (*Entry, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	elem, ok := s.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	e := elem.Value.(*memoryEntry)
+	if !time.Now().Before(e.expires) {
+		s.remove(elem)
+		return nil, false, nil
+	}
+	s.lru.MoveToFront(elem)
+	return e.entry, true, nil
+}
+
+// Set 保存条目，超过 MaxBytes 的单个条目不保存
+func (s *MemoryStore) Set(_ context.Context, key string, entry *Entry, ttl time.Duration) error {
+	size := entry.Size() + len(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if elem, ok := s.entries[key]; ok {
+		s.remove(elem)
+	}
+	if size > s.cfg.MaxBytes || ttl <= 0 {
+		return nil
+	}
+
+	s.entries[key] = s.lru.PushFront(&memoryEntry{key: key, entry: entry, size: size, expires: time.Now().Add(ttl)})
+	s.bytes += size
+	for _, tag := range entry.Tags {
+		keys, ok := s.tags[tag]
+		if !ok {
+			keys = make(map[string]struct{})
+			s.tags[tag] = keys
+		}
+		keys[key] = struct{}{}
+	}
+	for s.lru.Len() > s.cfg.MaxEntries || s.bytes > s.cfg.MaxBytes {
+		s.remove(s.lru.Back())
+	}
+	return nil
+}
+
+// Delete 删除条目
+func (s *MemoryStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if elem, ok := s.entries[key]; ok {
+		s.remove(elem)
+	}
+	return nil
+}
+
+// InvalidateTags 删除带有任一标签的条目
+func (s *MemoryStore) InvalidateTags(_ context.Context, tags ...string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	removed := 0
+	for _, tag := range tags {
+		for key := range s.tags[tag] {
+			if elem, ok := s.entries[key]; ok {
+				s.remove(elem)
+				removed++
+			}
+		}
+	}
+	return removed, nil
+}
+
+// Len 当前的条目数
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lru.Len()
+}
+
+// remove 删除条目及其标签索引，调用方需要持有锁
+func (s *MemoryStore) remove(elem *list.Element) {
+	e := s.lru.Remove(elem).(*memoryEntry)
+	delete(s.entries, e.key)
+	s.bytes -= e.size
+	for _, tag := range e.entry.Tags {
+		if keys, ok := s.tags[tag]; ok {
+			delete(keys, e.key)
+			if len(keys) == 0 {
+				delete(s.tags, tag)
+			}
+		}
+	}
+}
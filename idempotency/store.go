@@ -0,0 +1,117 @@
+package idempotency
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Record 一个幂等 key 的记录
+type Record struct {
+	// Fingerprint 请求的指纹，同一个 key 的请求指纹不同说明 key 被误用
+	Fingerprint string `json:"fingerprint"`
+	// Completed 为 false 表示第一个请求还在处理中
+	Completed  bool        `json:"completed"`
+	StatusCode int         `json:"statusCode,omitempty"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       []byte      `json:"body,omitempty"`
+	// BodyTooLarge 响应体超过大小限制没有保存，重放时无法返回原响应
+	BodyTooLarge bool      `json:"bodyTooLarge,omitempty"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// Store 幂等记录存储
+type Store interface {
+	// Begin 原子地占用 key：key 不存在时保存一条处理中的记录并返回 (nil, true)，
+	// 已存在时返回已有的记录和 false。ttl 为处理中记录的有效期，防止进程崩溃后 key 永久被占用
+	Begin(ctx context.Context, key, fingerprint string, ttl time.Duration) (*Record, bool, error)
+	// Complete 保存完成的响应，ttl 为可以重放的时间
+	Complete(ctx context.Context, key string, record *Record, ttl time.Duration) error
+	// Release 删除记录，处理失败时调用，允许客户端重试
+	Release(ctx context.Context, key string) error
+}
+
+// MemoryStore 内存中的幂等记录存储，后台定期清理过期的记录，不再使用时需要调用 Close
+type MemoryStore struct {
+	mu      sync.Mutex
+	records map[string]*memoryRecord
+	stop    chan struct{}
+	once    sync.Once
+}
+
+type memoryRecord struct {
+	record  Record
+	expires time.Time
+}
+
+// NewMemoryStore 创建内存存储，cleanupInterval 为清理过期记录的间隔，默认 1 分钟
+func NewMemoryStore(cleanupInterval time.Duration) *MemoryStore {
+	if cleanupInterval <= 0 {
+		cleanupInterval = time.Minute
+	}
+	s := &MemoryStore{
+		records: make(map[string]*memoryRecord),
+		stop:    make(chan struct{}),
+	}
+	go s.janitor(cleanupInterval)
+	return s
+}
+
+// Begin 占用 key
+func (s *MemoryStore) Begin(_ context.Context, key, fingerprint string, ttl time.Duration) (*Record, bool, error) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r, ok := s.records[key]; ok && now.Before(r.expires) {
+		record := r.record
+		return &record, false, nil
+	}
+	s.records[key] = &memoryRecord{
+		record:  Record{Fingerprint: fingerprint, CreatedAt: now},
+		expires: now.Add(ttl),
+	}
+	return nil, true, nil
+}
+
+// Complete 保存响应
+func (s *MemoryStore) Complete(_ context.Context, key string, record *Record, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[key] = &memoryRecord{record: *record, expires: time.Now().Add(ttl)}
+	return nil
+}
+
+// Release 删除记录
+func (s *MemoryStore) Release(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+	return nil
+}
+
+// Close 停止后台清理
+func (s *MemoryStore) Close() error {
+	s.once.Do(func() { close(s.stop) })
+	return nil
+}
+
+// janitor 定期删除过期的记录
+func (s *MemoryStore) janitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case now := <-ticker.C:
+			s.mu.Lock()
+			for key, r := range s.records {
+				if now.After(r.expires) {
+					delete(s.records, key)
+				}
+			}
+			s.mu.Unlock()
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package jwt
+
+import (
+	"context"
+	"log/slog"
+)
+
+// claimsKey context 中保存声明的 key
+type claimsKey struct{}
+
+// NewContext 返回带有声明的 context
+func NewContext(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+// FromContext 获取 context 中的声明
+func FromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(*Claims)
+	return claims, ok && claims != nil
+}
+
+// LogAttrs 从 context 中的声明提取日志字段（user_id），用于 log.NewHandler 的 ContextExtractor
+func LogAttrs(ctx context.Context) []slog.Attr {
+	claims, ok := FromContext(ctx)
+	if !ok || claims.Subject == "" {
+		return nil
+	}
+	return []slog.Attr{slog.String("user_id", claims.Subject)}
+}
@@ -0,0 +1,289 @@
+package jwt
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/huabingli/go-common/jsonutil"
+)
+
+// defaultJWKSTimeout 请求 JWKS 的默认超时
+const defaultJWKSTimeout = 10 * time.Second
+
+// JWKSConfig JWKS 密钥集配置
+type JWKSConfig struct {
+	// Client 请求 JWKS 使用的 HTTP 客户端，默认超时 10 秒；没有设置 Timeout 时同样按 10 秒超时
+	Client *http.Client
+	// RefreshInterval 后台刷新间隔，默认 1 小时
+	RefreshInterval time.Duration
+	// MinRefreshInterval 遇到未知 kid 时按需刷新的最小间隔，防止伪造的 kid 打满 JWKS 服务，默认 30 秒
+	MinRefreshInterval time.Duration
+}
+
+// JWKS 从 URL 加载的密钥集，缓存在内存中并定期刷新，遇到未知 kid（密钥轮换）时立即刷新。
+// 刷新失败时继续使用旧的密钥，不再使用时需要调用 Close
+type JWKS struct {
+	url string
+	cfg JWKSConfig
+
+	mu          sync.RWMutex
+	keys        map[string]jwkKey
+	loaded      bool      // 是否成功加载过
+	lastAttempt time.Time // 最近一次加载的时间，无论成功与否
+	lastErr     error     // 最近一次加载的错误
+
+	refreshMu sync.Mutex
+	stop      chan struct{}
+	once      sync.Once
+}
+
+// jwkKey 解析后的密钥
+type jwkKey struct {
+	alg string
+	key any
+}
+
+// NewJWKS 使用默认配置创建 JWKS 密钥集
+func NewJWKS(url string) *JWKS {
+	return NewJWKSWithConfig(url, JWKSConfig{})
+}
+
+// NewJWKSWithConfig 按配置创建 JWKS 密钥集，第一次使用时加载，之后在后台定期刷新
+func NewJWKSWithConfig(url string, cfg JWKSConfig) *JWKS {
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: defaultJWKSTimeout}
+	}
+	if cfg.RefreshInterval <= 0 {
+		cfg.RefreshInterval = time.Hour
+	}
+	if cfg.MinRefreshInterval <= 0 {
+		cfg.MinRefreshInterval = 30 * time.Second
+	}
+	j := &JWKS{
+		url:  url,
+		cfg:  cfg,
+		keys: make(map[string]jwkKey),
+		stop: make(chan struct{}),
+	}
+	go j.refreshLoop()
+	return j
+}
+
+// Key 返回 kid 对应的密钥。第一次使用时加载密钥集，加载失败时返回该错误；
+// 之后遇到未知的 kid 时，距离上次加载超过 MinRefreshInterval 才重新加载
+func (j *JWKS) Key(ctx context.Context, kid, alg string) (any, error) {
+	key, ok, loaded, lastAttempt := j.lookup(kid)
+	if !ok && (!loaded || time.Since(lastAttempt) >= j.cfg.MinRefreshInterval) {
+		if err := j.Refresh(ctx); err != nil {
+			return nil, err
+		}
+		key, ok, _, _ = j.lookup(kid)
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: kid=%q", ErrUnknownKey, kid)
+	}
+	if key.alg != "" && key.alg != alg {
+		return nil, fmt.Errorf("%w: 密钥 %q 只能用于 %s", ErrAlgorithm, kid, key.alg)
+	}
+	return key.key, nil
+}
+
+// lookup 查找密钥，令牌没有 kid 且只有一个密钥时使用该密钥
+func (j *JWKS) lookup(kid string) (key jwkKey, ok, loaded bool, lastAttempt time.Time) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	key, ok = j.keys[kid]
+	if !ok && kid == "" && len(j.keys) == 1 {
+		for _, k := range j.keys {
+			key, ok = k, true
+		}
+	}
+	return key, ok, j.loaded, j.lastAttempt
+}
+
+// Refresh 立即重新加载密钥集，并发调用时只请求一次，等待的调用返回同一次加载的结果。
+// 加载的密钥集由所有请求共享，因此不受 ctx 取消的影响，只受 Client 超时的限制
+func (j *JWKS) Refresh(ctx context.Context) error {
+	started := time.Now()
+	j.refreshMu.Lock()
+	defer j.refreshMu.Unlock()
+	j.mu.RLock()
+	fresh, lastErr := j.lastAttempt.After(started), j.lastErr
+	j.mu.RUnlock()
+	if fresh {
+		// 等锁期间其他 goroutine 已经加载过
+		return lastErr
+	}
+
+	fetchCtx := context.WithoutCancel(ctx)
+	if j.cfg.Client.Timeout <= 0 {
+		var cancel context.CancelFunc
+		fetchCtx, cancel = context.WithTimeout(fetchCtx, defaultJWKSTimeout)
+		defer cancel()
+	}
+	keys, err := j.fetch(fetchCtx)
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	// 失败时也记录时间，成功加载过之后按 MinRefreshInterval 退避，避免持续请求不可用的 JWKS 服务
+	j.lastAttempt = time.Now()
+	j.lastErr = err
+	if err != nil {
+		return err
+	}
+	j.keys = keys
+	j.loaded = true
+	return nil
+}
+
+// Close 停止后台刷新
+func (j *JWKS) Close() error {
+	j.once.Do(func() { close(j.stop) })
+	return nil
+}
+
+// refreshLoop 定期刷新密钥集
+func (j *JWKS) refreshLoop() {
+	ticker := time.NewTicker(j.cfg.RefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-j.stop:
+			return
+		case <-ticker.C:
+			if err := j.Refresh(context.Background()); err != nil {
+				slog.Warn("刷新 JWKS 失败，继续使用缓存的密钥", slog.String("url", j.url), slog.Any("err", err))
+			}
+		}
+	}
+}
+
+// jwk JSON Web Key 中需要的字段
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+// fetch 请求并解析 JWKS，跳过不支持或用途不是签名的密钥
+func (j *JWKS) fetch(ctx context.Context) (map[string]jwkKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := j.cfg.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("jwt: 请求 JWKS 失败: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwt: 请求 JWKS 返回 %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("jwt: 读取 JWKS 失败: %w", err)
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := jsonutil.Unmarshal(body, &set); err != nil {
+		return nil, fmt.Errorf("jwt: 解析 JWKS 失败: %w", err)
+	}
+
+	keys := make(map[string]jwkKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, alg, err := k.parse()
+		if err != nil {
+			slog.Warn("忽略无效的 JWK", slog.String("kid", k.Kid), slog.Any("err", err))
+			continue
+		}
+		if k.Alg != "" {
+			alg = k.Alg
+		}
+		keys[k.Kid] = jwkKey{alg: alg, key: key}
+	}
+	return keys, nil
+}
+
+// parse 解析为验证密钥，返回密钥和对应的算法
+func (k jwk) parse() (any, string, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, "", err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, "", err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, "", fmt.Errorf("无效的 RSA 指数")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, RS256, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, "", fmt.Errorf("不支持的曲线 %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, "", err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, "", err
+		}
+		curve := elliptic.P256()
+		if !curve.IsOnCurve(x, y) {
+			return nil, "", fmt.Errorf("EC 公钥不在曲线上")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, ES256, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, "", fmt.Errorf("不支持的曲线 %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, "", fmt.Errorf("无效的 Ed25519 公钥")
+		}
+		return ed25519.PublicKey(x), EdDSA, nil
+	case "oct":
+		secret, err := base64.RawURLEncoding.DecodeString(k.K)
+		if err != nil || len(secret) == 0 {
+			return nil, "", fmt.Errorf("无效的对称密钥")
+		}
+		return secret, HS256, nil
+	default:
+		return nil, "", fmt.Errorf("不支持的密钥类型 %q", k.Kty)
+	}
+}
+
+// decodeBigInt 解码 base64url 编码的大整数
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, fmt.Errorf("无效的 base64url 整数")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
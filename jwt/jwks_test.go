@@ -0,0 +1,223 @@
+package jwt
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/huabingli/go-common/jsonutil"
+)
+
+// jwksServer 可以在测试中替换密钥的 JWKS 服务，记录请求次数
+type jwksServer struct {
+	*httptest.Server
+	mu   sync.Mutex
+	keys []jwk
+	hits atomic.Int32
+	fail atomic.Bool
+}
+
+func newJWKSServer(t *testing.T, keys ...jwk) *jwksServer {
+	t.Helper()
+	s := &jwksServer{keys: keys}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.hits.Add(1)
+		if s.fail.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		s.mu.Lock()
+		set := map[string][]jwk{"keys": s.keys}
+		s.mu.Unlock()
+		body, _ := jsonutil.Marshal(&set)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(body)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *jwksServer) setKeys(keys ...jwk) {
+	s.mu.Lock()
+	s.keys = keys
+	s.mu.Unlock()
+}
+
+func newTestJWKS(t *testing.T, url string, minRefresh time.Duration) *JWKS {
+	t.Helper()
+	j := NewJWKSWithConfig(url, JWKSConfig{MinRefreshInterval: minRefresh})
+	t.Cleanup(func() { _ = j.Close() })
+	return j
+}
+
+func generateRSA(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// rsaJWK 将 RSA 公钥编码为 JWK
+func rsaJWK(kid string, key *rsa.PrivateKey) jwk {
+	return jwk{
+		Kty: "RSA",
+		Kid: kid,
+		Alg: RS256,
+		Use: "sig",
+		N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func sign(t *testing.T, claims *Claims, alg, kid string, key any) string {
+	t.Helper()
+	token, err := Sign(claims, alg, kid, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestJWKSKeyRotation(t *testing.T) {
+	key1, key2 := generateRSA(t), generateRSA(t)
+	srv := newJWKSServer(t, rsaJWK("k1", key1))
+	v := NewVerifier(Config{Keys: newTestJWKS(t, srv.URL, time.Millisecond)})
+	ctx := context.Background()
+
+	if _, err := v.Verify(ctx, sign(t, &Claims{Subject: "a"}, RS256, "k1", key1)); err != nil {
+		t.Fatalf("k1: %v", err)
+	}
+	srv.setKeys(rsaJWK("k1", key1), rsaJWK("k2", key2))
+	time.Sleep(5 * time.Millisecond)
+
+	claims, err := v.Verify(ctx, sign(t, &Claims{Subject: "b"}, RS256, "k2", key2))
+	if err != nil {
+		t.Fatalf("k2 after rotation: %v", err)
+	}
+	if claims.Subject != "b" {
+		t.Fatalf("subject = %q", claims.Subject)
+	}
+	if got := srv.hits.Load(); got != 2 {
+		t.Fatalf("hits = %d, want 2", got)
+	}
+
+	// 旧密钥下线后，用它签发的令牌不再有效
+	srv.setKeys(rsaJWK("k2", key2))
+	v = NewVerifier(Config{Keys: newTestJWKS(t, srv.URL, time.Hour)})
+	if _, err := v.Verify(ctx, sign(t, &Claims{}, RS256, "k1", key1)); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("retired k1: err = %v, want ErrUnknownKey", err)
+	}
+}
+
+func TestJWKSUnknownKidRateLimited(t *testing.T) {
+	key1, key2 := generateRSA(t), generateRSA(t)
+	srv := newJWKSServer(t, rsaJWK("k1", key1))
+	v := NewVerifier(Config{Keys: newTestJWKS(t, srv.URL, time.Hour)})
+	ctx := context.Background()
+
+	if _, err := v.Verify(ctx, sign(t, &Claims{}, RS256, "k1", key1)); err != nil {
+		t.Fatalf("k1: %v", err)
+	}
+	srv.setKeys(rsaJWK("k1", key1), rsaJWK("k2", key2))
+
+	// 距离上次加载不足 MinRefreshInterval，未知 kid 不触发请求
+	for range 5 {
+		if _, err := v.Verify(ctx, sign(t, &Claims{}, RS256, "forged", key2)); !errors.Is(err, ErrUnknownKey) {
+			t.Fatalf("forged kid: err = %v, want ErrUnknownKey", err)
+		}
+	}
+	if _, err := v.Verify(ctx, sign(t, &Claims{}, RS256, "k2", key2)); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("k2 within MinRefreshInterval: err = %v, want ErrUnknownKey", err)
+	}
+	if got := srv.hits.Load(); got != 1 {
+		t.Fatalf("hits = %d, want 1", got)
+	}
+}
+
+func TestJWKSFirstLoadFailure(t *testing.T) {
+	key := generateRSA(t)
+	srv := newJWKSServer(t, rsaJWK("k1", key))
+	v := NewVerifier(Config{Keys: newTestJWKS(t, srv.URL, time.Hour)})
+	token := sign(t, &Claims{}, RS256, "k1", key)
+
+	// 第一次加载失败返回加载的错误而不是 ErrUnknownKey，也不进入 MinRefreshInterval 退避
+	srv.fail.Store(true)
+	_, err := v.Verify(context.Background(), token)
+	if err == nil || errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("failed load: err = %v, want fetch error", err)
+	}
+	srv.fail.Store(false)
+
+	// 发起加载的请求已经取消，共享的加载仍然完成
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := v.Verify(ctx, token); err != nil {
+		t.Fatalf("canceled ctx: %v", err)
+	}
+	if got := srv.hits.Load(); got != 2 {
+		t.Fatalf("hits = %d, want 2", got)
+	}
+}
+
+func TestJWKSAlgorithmConfusion(t *testing.T) {
+	key := generateRSA(t)
+	srv := newJWKSServer(t, rsaJWK("k1", key))
+	v := NewVerifier(Config{Keys: newTestJWKS(t, srv.URL, time.Hour)})
+	ctx := context.Background()
+
+	// 攻击者把 RSA 公钥当作 HS256 的密钥签名
+	forged := sign(t, &Claims{Subject: "admin"}, HS256, "k1", key.N.Bytes())
+	if _, err := v.Verify(ctx, forged); !errors.Is(err, ErrAlgorithm) {
+		t.Fatalf("JWKS: err = %v, want ErrAlgorithm", err)
+	}
+
+	// 没有 alg 限制的静态密钥也不能被当作 HMAC 密钥
+	static := NewVerifier(Config{Keys: StaticKeys{"k1": &key.PublicKey}})
+	if _, err := static.Verify(ctx, forged); !errors.Is(err, ErrAlgorithm) {
+		t.Fatalf("StaticKeys: err = %v, want ErrAlgorithm", err)
+	}
+}
+
+func TestVerifyLeeway(t *testing.T) {
+	key := generateRSA(t)
+	now := time.Unix(1_700_000_000, 0)
+	v := NewVerifier(Config{
+		Keys:   StaticKeys{"k1": &key.PublicKey},
+		Leeway: 30 * time.Second,
+		Now:    func() time.Time { return now },
+	})
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		claims *Claims
+		want   error
+	}{
+		{"exp within leeway", &Claims{ExpiresAt: NewNumericDate(now.Add(-20 * time.Second))}, nil},
+		{"exp beyond leeway", &Claims{ExpiresAt: NewNumericDate(now.Add(-30 * time.Second))}, ErrExpired},
+		{"nbf within leeway", &Claims{NotBefore: NewNumericDate(now.Add(30 * time.Second))}, nil},
+		{"nbf beyond leeway", &Claims{NotBefore: NewNumericDate(now.Add(31 * time.Second))}, ErrNotValidYet},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := v.Verify(ctx, sign(t, tt.claims, RS256, "k1", key))
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+			if err != nil && claims != nil {
+				t.Fatalf("claims = %+v, want nil on error", claims)
+			}
+		})
+	}
+}
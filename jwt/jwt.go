@@ -0,0 +1,334 @@
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/huabingli/go-common/jsonutil"
+)
+
+// 支持的签名算法
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+	ES256 = "ES256"
+	EdDSA = "EdDSA"
+)
+
+// MinHMACKeySize HS256 密钥的最小字节数，与 SHA-256 的输出长度相同，过短的密钥可以被暴力破解
+const MinHMACKeySize = 32
+
+var (
+	ErrMalformed   = errors.New("jwt: 令牌格式错误")
+	ErrAlgorithm   = errors.New("jwt: 不允许的签名算法")
+	ErrUnknownKey  = errors.New("jwt: 找不到签名密钥")
+	ErrSignature   = errors.New("jwt: 签名无效")
+	ErrExpired     = errors.New("jwt: 令牌已过期")
+	ErrNotValidYet = errors.New("jwt: 令牌尚未生效")
+	ErrIssuer      = errors.New("jwt: 签发者不匹配")
+	ErrAudience    = errors.New("jwt: 受众不匹配")
+	ErrWeakKey     = errors.New("jwt: HS256 密钥过短")
+)
+
+// NumericDate JWT 中以秒为单位的时间戳
+type NumericDate struct {
+	time.Time
+}
+
+// NewNumericDate 创建时间戳，精度为秒
+func NewNumericDate(t time.Time) *NumericDate {
+	return &NumericDate{t.Truncate(time.Second)}
+}
+
+func (d NumericDate) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.FormatInt(d.Unix(), 10)), nil
+}
+
+func (d *NumericDate) UnmarshalJSON(data []byte) error {
+	f, err := strconv.ParseFloat(string(data), 64)
+	if err != nil {
+		return fmt.Errorf("%w: 无效的时间戳 %s", ErrMalformed, data)
+	}
+	sec, frac := math.Modf(f)
+	d.Time = time.Unix(int64(sec), int64(frac*1e9))
+	return nil
+}
+
+// Audience aud 可以是字符串或字符串数组
+type Audience []string
+
+func (a Audience) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return jsonutil.Marshal(&a[0])
+	}
+	list := []string(a)
+	return jsonutil.Marshal(&list)
+}
+
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := jsonutil.Unmarshal(data, &single); err == nil {
+		*a = Audience{single}
+		return nil
+	}
+	var list []string
+	if err := jsonutil.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("%w: 无效的 aud", ErrMalformed)
+	}
+	*a = list
+	return nil
+}
+
+// Claims 注册声明，其余的自定义声明通过 Custom 解码
+type Claims struct {
+	Issuer    string       `json:"iss,omitempty"`
+	Subject   string       `json:"sub,omitempty"`
+	Audience  Audience     `json:"aud,omitempty"`
+	ExpiresAt *NumericDate `json:"exp,omitempty"`
+	NotBefore *NumericDate `json:"nbf,omitempty"`
+	IssuedAt  *NumericDate `json:"iat,omitempty"`
+	ID        string       `json:"jti,omitempty"`
+
+	payload []byte
+}
+
+// Custom 将令牌的 payload 解码为自定义结构，如 jwt.Custom[MyClaims](claims)
+func Custom[T any](c *Claims) (T, error) {
+	var v T
+	err := jsonutil.Unmarshal(c.payload, &v)
+	return v, err
+}
+
+// header JOSE 头部
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid,omitempty"`
+	Typ string `json:"typ,omitempty"`
+}
+
+// Config 令牌校验配置
+type Config struct {
+	// Keys 验证签名的密钥，必填，见 StaticKeys 和 JWKS
+	Keys KeyProvider
+	// Algorithms 允许的签名算法，默认 HS256、RS256、ES256 和 EdDSA
+	Algorithms []string
+	// Issuer 非空时要求 iss 一致
+	Issuer string
+	// Audience 非空时要求 aud 包含该值
+	Audience string
+	// Leeway 校验 exp、nbf 时允许的时钟偏差
+	Leeway time.Duration
+	// RequireExpiration 要求令牌必须有 exp
+	RequireExpiration bool
+	// Now 当前时间，默认 time.Now，用于测试
+	Now func() time.Time
+}
+
+// Verifier 令牌校验器，可以在多个 goroutine 中同时使用
+type Verifier struct {
+	cfg Config
+}
+
+// NewVerifier 创建令牌校验器，Keys 为空时 panic
+func NewVerifier(cfg Config) *Verifier {
+	if cfg.Keys == nil {
+		panic("jwt: Config.Keys 不能为空")
+	}
+	if len(cfg.Algorithms) == 0 {
+		cfg.Algorithms = []string{HS256, RS256, ES256, EdDSA}
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	return &Verifier{cfg: cfg}
+}
+
+// Verify 校验签名和 exp、nbf、iss、aud，返回声明，校验失败时声明为 nil
+func (v *Verifier) Verify(ctx context.Context, token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformed
+	}
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	var h header
+	if err := jsonutil.Unmarshal(headerJSON, &h); err != nil {
+		return nil, ErrMalformed
+	}
+	if !slices.Contains(v.cfg.Algorithms, h.Alg) {
+		return nil, fmt.Errorf("%w: %q", ErrAlgorithm, h.Alg)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+
+	key, err := v.cfg.Keys.Key(ctx, h.Kid, h.Alg)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(h.Alg, key, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	claims := &Claims{payload: payload}
+	if err := jsonutil.Unmarshal(payload, claims); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	if err := v.validate(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// validate 校验时间和签发者、受众
+func (v *Verifier) validate(c *Claims) error {
+	now := v.cfg.Now()
+	if c.ExpiresAt == nil {
+		if v.cfg.RequireExpiration {
+			return fmt.Errorf("%w: 缺少 exp", ErrExpired)
+		}
+	} else if !now.Before(c.ExpiresAt.Add(v.cfg.Leeway)) {
+		return ErrExpired
+	}
+	if c.NotBefore != nil && now.Add(v.cfg.Leeway).Before(c.NotBefore.Time) {
+		return ErrNotValidYet
+	}
+	if v.cfg.Issuer != "" && c.Issuer != v.cfg.Issuer {
+		return ErrIssuer
+	}
+	if v.cfg.Audience != "" && !slices.Contains(c.Audience, v.cfg.Audience) {
+		return ErrAudience
+	}
+	return nil
+}
+
+// verifySignature 按算法校验签名，密钥类型与算法不匹配时返回 ErrAlgorithm
+func verifySignature(alg string, key any, signingInput string, signature []byte) error {
+	digest := sha256.Sum256([]byte(signingInput))
+	switch alg {
+	case HS256:
+		secret, ok := key.([]byte)
+		if !ok {
+			return ErrAlgorithm
+		}
+		if len(secret) < MinHMACKeySize {
+			return ErrWeakKey
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(signingInput))
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return ErrSignature
+		}
+	case RS256:
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return ErrAlgorithm
+		}
+		if rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], signature) != nil {
+			return ErrSignature
+		}
+	case ES256:
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok || pub.Curve != elliptic.P256() {
+			return ErrAlgorithm
+		}
+		if len(signature) != 64 {
+			return ErrSignature
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(pub, digest[:], r, s) {
+			return ErrSignature
+		}
+	case EdDSA:
+		pub, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return ErrAlgorithm
+		}
+		if !ed25519.Verify(pub, []byte(signingInput), signature) {
+			return ErrSignature
+		}
+	default:
+		return ErrAlgorithm
+	}
+	return nil
+}
+
+// Sign 签发令牌，claims 可以是 Claims 或包含注册声明的自定义结构。
+// key 的类型：HS256 为 []byte，RS256 为 *rsa.PrivateKey，ES256 为 *ecdsa.PrivateKey，EdDSA 为 ed25519.PrivateKey
+func Sign(claims any, alg, kid string, key any) (string, error) {
+	headerJSON, err := jsonutil.Marshal(&header{Alg: alg, Kid: kid, Typ: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := jsonutil.Marshal(&claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+
+	var signature []byte
+	switch k := key.(type) {
+	case []byte:
+		if alg != HS256 {
+			return "", ErrAlgorithm
+		}
+		if len(k) < MinHMACKeySize {
+			return "", ErrWeakKey
+		}
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(signingInput))
+		signature = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		if alg != RS256 {
+			return "", ErrAlgorithm
+		}
+		signature, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		if alg != ES256 || k.Curve != elliptic.P256() {
+			return "", ErrAlgorithm
+		}
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, k, digest[:])
+		if err == nil {
+			signature = make([]byte, 64)
+			r.FillBytes(signature[:32])
+			s.FillBytes(signature[32:])
+		}
+	case ed25519.PrivateKey:
+		if alg != EdDSA {
+			return "", ErrAlgorithm
+		}
+		signature = ed25519.Sign(k, []byte(signingInput))
+	default:
+		return "", fmt.Errorf("%w: 不支持的密钥类型 %T", ErrAlgorithm, key)
+	}
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
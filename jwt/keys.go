@@ -0,0 +1,28 @@
+package jwt
+
+import (
+	"context"
+	"fmt"
+)
+
+// KeyProvider 按 kid 和算法提供验证签名的密钥：
+// HS256 为 []byte（至少 MinHMACKeySize 字节），RS256 为 *rsa.PublicKey，ES256 为 *ecdsa.PublicKey，EdDSA 为 ed25519.PublicKey
+type KeyProvider interface {
+	Key(ctx context.Context, kid, alg string) (any, error)
+}
+
+// StaticKeys 固定的密钥，kid 到密钥的映射。令牌没有 kid 且只有一个密钥时使用该密钥
+type StaticKeys map[string]any
+
+// Key 返回 kid 对应的密钥
+func (s StaticKeys) Key(_ context.Context, kid, _ string) (any, error) {
+	if key, ok := s[kid]; ok {
+		return key, nil
+	}
+	if kid == "" && len(s) == 1 {
+		for _, key := range s {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: kid=%q", ErrUnknownKey, kid)
+}
@@ -5,15 +5,21 @@ import (
 	"log/slog"
 )
 
+// ContextExtractor 从 context 中提取需要附加到每条日志的字段，如 jwt.LogAttrs 提取 user_id
+type ContextExtractor func(ctx context.Context) []slog.Attr
+
 type Handler struct {
 	handler      slog.Handler
 	requestIDKey string // 这里可以是 string 或其他类型
+	extractors   []ContextExtractor
 }
 
-func NewHandler(handler slog.Handler, requestIDKey string) slog.Handler {
+// NewHandler 包装 handler，从 context 中读取 request ID 以及 extractors 提取的字段附加到每条日志
+func NewHandler(handler slog.Handler, requestIDKey string, extractors ...ContextExtractor) slog.Handler {
 	return Handler{
 		handler:      handler,
 		requestIDKey: requestIDKey,
+		extractors:   extractors,
 	}
 }
 func (h Handler) Enabled(ctx context.Context, level slog.Level) bool {
@@ -23,6 +29,7 @@ func (h Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
 	return Handler{
 		handler:      h.handler.WithAttrs(attrs),
 		requestIDKey: h.requestIDKey,
+		extractors:   h.extractors,
 	}
 }
 
@@ -30,11 +37,31 @@ func (h Handler) WithGroup(name string) slog.Handler {
 	return Handler{
 		handler:      h.handler.WithGroup(name),
 		requestIDKey: h.requestIDKey,
+		extractors:   h.extractors,
 	}
 }
 func (h Handler) Handle(ctx context.Context, record slog.Record) error {
-	if requestID, ok := ctx.Value(h.requestIDKey).(string); ok {
+	if requestID, ok := ctx.Value(h.requestIDKey).(string); ok && !hasAttr(record, "request_id") {
 		record.AddAttrs(slog.String("request_id", requestID))
 	}
+	for _, extract := range h.extractors {
+		for _, attr := range extract(ctx) {
+			if !hasAttr(record, attr.Key) {
+				record.AddAttrs(attr)
+			}
+		}
+	}
 	return h.handler.Handle(ctx, record)
 }
+
+// hasAttr 判断日志记录中是否已经有 key 对应的字段，避免重复输出
+func hasAttr(record slog.Record, key string) bool {
+	found := false
+	record.Attrs(
+		func(a slog.Attr) bool {
+			found = a.Key == key
+			return !found
+		},
+	)
+	return found
+}
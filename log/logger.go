@@ -11,8 +11,9 @@ import (
 	"gopkg.in/natefinch/lumberjack.v2"
 )
 
-// InitSlog 初始化日志器，支持开发模式和生产模式，日志级别和源信息选择
-func InitSlog(level slog.Level, addSource, dev bool, requestIDKey string) *slog.Logger {
+// InitSlog 初始化日志器，支持开发模式和生产模式，日志级别和源信息选择，
+// extractors 从 context 中提取附加到每条日志的字段，如 jwt.LogAttrs
+func InitSlog(level slog.Level, addSource, dev bool, requestIDKey string, extractors ...ContextExtractor) *slog.Logger {
 	opts := slog.HandlerOptions{
 		AddSource:   addSource,
 		Level:       level,
@@ -38,7 +39,7 @@ func InitSlog(level slog.Level, addSource, dev bool, requestIDKey string) *slog.
 		// 生产环境下使用 JSON 格式输出
 		handler = slog.NewJSONHandler(os.Stderr, &opts)
 	}
-	logger := slog.New(NewHandler(handler, requestIDKey))
+	logger := slog.New(NewHandler(handler, requestIDKey, extractors...))
 	slog.SetDefault(logger)
 	return logger
 }
@@ -55,6 +56,8 @@ type LoggerConfig struct {
 	Console      bool   // 是否输出到控制台
 	RequestIDKey string
 	ErrorStack   bool // 是否输出错误的stacktrace
+	// ContextExtractors 从 context 中提取附加到每条日志的字段，如 jwt.LogAttrs
+	ContextExtractors []ContextExtractor
 }
 
 func NewLogger(cfg LoggerConfig) *slog.Logger {
@@ -114,7 +117,7 @@ func NewLogger(cfg LoggerConfig) *slog.Logger {
 	default:
 		handler = slog.NewJSONHandler(combinedWriter, &opts)
 	}
-	logger := slog.New(NewHandler(handler, cfg.RequestIDKey))
+	logger := slog.New(NewHandler(handler, cfg.RequestIDKey, cfg.ContextExtractors...))
 	slog.SetDefault(logger)
 	return logger
 }
@@ -3,9 +3,9 @@ package log
 import (
 	"log/slog"
 	"reflect"
-	"runtime"
-	"strings"
 	"time"
+
+	"github.com/huabingli/go-common/errors"
 )
 
 func ReplaceAttr(errorStack bool) func([]string, slog.Attr) slog.Attr {
@@ -22,11 +22,7 @@ func ReplaceAttr(errorStack bool) func([]string, slog.Attr) slog.Attr {
 				return a
 			}
 			if src, ok := a.Value.Any().(error); ok && src != nil {
-				a.Value = slog.GroupValue(
-					slog.String("msg", src.Error()),
-					slog.String("type", reflect.TypeOf(src).String()),
-					slog.String("stack", stacktrace()), // 建议统一叫 "stack"
-				)
+				a.Value = errorValue(src)
 			}
 			return a
 
@@ -37,14 +33,29 @@ func ReplaceAttr(errorStack bool) func([]string, slog.Attr) slog.Attr {
 
 }
 
-func stacktrace() string {
-	buf := make([]byte, 64*1024)
-	n := runtime.Stack(buf, false)
-	trace := string(buf[:n])
+// errorValue 将错误展开为结构化的日志字段，调用栈来自错误创建时记录的栈帧，
+// 没有记录调用栈的普通错误只输出 msg 和 type
+func errorValue(err error) slog.Value {
+	attrs := []slog.Attr{
+		slog.String("msg", err.Error()),
+		slog.String("type", reflect.TypeOf(err).String()),
+	}
+	if code := errors.Code(err); code != "" {
+		attrs = append(attrs, slog.String("code", code))
+	}
+	if fields := errors.Fields(err); len(fields) > 0 {
+		attrs = append(attrs, slog.Attr{Key: "fields", Value: slog.GroupValue(fields...)})
+	}
 
-	// 去掉 "goroutine xxx" 那一行
-	if idx := strings.Index(trace, "\n"); idx != -1 {
-		return trace[idx+1:]
+	// 错误链的第一层就是 err 本身，其余的作为 causes 输出
+	chain := errors.Chain(err)
+	if len(chain) > 0 {
+		if len(chain[0].Stack) > 0 {
+			attrs = append(attrs, slog.Any("stack", chain[0].Stack))
+		}
+		if len(chain) > 1 {
+			attrs = append(attrs, slog.Any("causes", chain[1:]))
+		}
 	}
-	return trace
+	return slog.GroupValue(attrs...)
 }
@@ -0,0 +1,135 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// MinExponentialScale 指数直方图的最小精度，桶的增长因子为 2^16
+	MinExponentialScale = -4
+	// MaxExponentialScale 指数直方图的最大精度，桶的增长因子约为 1.0027
+	MaxExponentialScale = 8
+)
+
+// ExponentialHistogram 指数桶直方图，不需要预先指定桶，按需创建桶。
+// 第 i 个桶的上界为 2^(i/2^scale)，scale 越大桶越密，与 OpenTelemetry 的指数直方图一致
+type ExponentialHistogram struct {
+	scale   int
+	factor  float64       // 2^scale
+	zero    atomic.Uint64 // 小于等于 0 的观测值个数
+	inf     atomic.Uint64 // +Inf 的观测值个数
+	buckets sync.Map      // 桶序号 int -> *atomic.Uint64
+	sum     atomic.Uint64
+	count   atomic.Uint64
+}
+
+func newExponentialHistogram(scale int) *ExponentialHistogram {
+	return &ExponentialHistogram{scale: scale, factor: math.Ldexp(1, scale)}
+}
+
+// Observe 记录一个观测值，NaN 会被忽略
+func (h *ExponentialHistogram) Observe(v float64) {
+	switch {
+	case math.IsNaN(v):
+		return
+	case v <= 0:
+		h.zero.Add(1)
+	case math.IsInf(v, 1):
+		h.inf.Add(1)
+	default:
+		idx := h.index(v)
+		counter, ok := h.buckets.Load(idx)
+		if !ok {
+			counter, _ = h.buckets.LoadOrStore(idx, new(atomic.Uint64))
+		}
+		counter.(*atomic.Uint64).Add(1)
+	}
+	addFloat(&h.sum, v)
+	h.count.Add(1)
+}
+
+// index 返回 v 所在桶的序号，修正浮点误差使得 upperBound(idx-1) < v <= upperBound(idx)
+func (h *ExponentialHistogram) index(v float64) int {
+	idx := int(math.Ceil(math.Log2(v) * h.factor))
+	if v > h.upperBound(idx) {
+		idx++
+	} else if v <= h.upperBound(idx-1) {
+		idx--
+	}
+	return idx
+}
+
+// upperBound 返回第 idx 个桶的上界
+func (h *ExponentialHistogram) upperBound(idx int) float64 {
+	return math.Exp2(float64(idx) / h.factor)
+}
+
+// Value 返回直方图快照，只包含有观测值的桶，
+// 小于等于 0 的观测值归入上界为 0 的桶
+func (h *ExponentialHistogram) Value() HistogramValue {
+	var indexes []int
+	h.buckets.Range(
+		func(key, _ any) bool {
+			indexes = append(indexes, key.(int))
+			return true
+		},
+	)
+	sort.Ints(indexes)
+
+	v := HistogramValue{
+		Buckets: make([]Bucket, 0, len(indexes)+2),
+		Sum:     math.Float64frombits(h.sum.Load()),
+		Count:   h.count.Load(),
+	}
+	cumulative := h.zero.Load()
+	if cumulative > 0 {
+		v.Buckets = append(v.Buckets, Bucket{UpperBound: 0, Count: cumulative})
+	}
+	for _, idx := range indexes {
+		counter, _ := h.buckets.Load(idx)
+		cumulative += counter.(*atomic.Uint64).Load()
+		v.Buckets = append(v.Buckets, Bucket{UpperBound: h.upperBound(idx), Count: cumulative})
+	}
+	cumulative += h.inf.Load()
+	v.Buckets = append(v.Buckets, Bucket{UpperBound: math.Inf(1), Count: cumulative})
+	return v
+}
+
+// ExponentialHistogramVec 带标签的指数桶直方图
+type ExponentialHistogramVec struct {
+	f *family
+}
+
+// NewExponentialHistogramVec 注册带标签的指数桶直方图，scale 的范围为 [MinExponentialScale, MaxExponentialScale]，
+// 输出时只包含有观测值的桶，scale 为 3 时相邻桶的上界相差约 9%
+func (r *Registry) NewExponentialHistogramVec(name, help string, scale int, labels ...string) *ExponentialHistogramVec {
+	if scale < MinExponentialScale || scale > MaxExponentialScale {
+		panic(fmt.Sprintf("metrics: %s 的 scale %d 超出范围 [%d, %d]", name, scale, MinExponentialScale, MaxExponentialScale))
+	}
+	f := r.register(
+		&family{
+			name:        name,
+			help:        help,
+			kind:        KindHistogram,
+			labels:      labels,
+			exponential: true,
+			scale:       scale,
+			newFn:       func() any { return newExponentialHistogram(scale) },
+		},
+	)
+	return &ExponentialHistogramVec{f: f}
+}
+
+// NewExponentialHistogram 注册不带标签的指数桶直方图
+func (r *Registry) NewExponentialHistogram(name, help string, scale int) *ExponentialHistogram {
+	return r.NewExponentialHistogramVec(name, help, scale).With()
+}
+
+// With 返回标签值对应的指数桶直方图
+func (v *ExponentialHistogramVec) With(values ...string) *ExponentialHistogram {
+	return v.f.with(values).(*ExponentialHistogram)
+}
@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"expvar"
+	"math"
+)
+
+// PublishExpvar 将注册表以 name 发布到 expvar（/debug/vars），每次读取时生成快照。
+// 与 expvar.Publish 一样，name 重复时 panic
+func (r *Registry) PublishExpvar(name string) {
+	expvar.Publish(name, expvar.Func(func() any { return r.Snapshot().expvarValue() }))
+}
+
+// expvarValue 转换为可以 JSON 序列化的结构：
+// {"指标名": [{"labels": {...}, "value": 1}]}，直方图为 {"labels": {...}, "count": 1, "sum": 0.1, "buckets": {"0.1": 1, "+Inf": 1}}
+func (s Snapshot) expvarValue() map[string]any {
+	result := make(map[string]any, len(s.Families))
+	for _, f := range s.Families {
+		samples := make([]map[string]any, 0, len(f.Samples))
+		for _, sample := range f.Samples {
+			item := map[string]any{"labels": f.LabelMap(sample)}
+			if h := sample.Histogram; h != nil {
+				buckets := make(map[string]uint64, len(h.Buckets))
+				for _, b := range h.Buckets {
+					buckets[formatFloat(b.UpperBound)] = b.Count
+				}
+				item["count"] = h.Count
+				item["sum"] = jsonFloat(h.Sum)
+				item["buckets"] = buckets
+			} else {
+				item["value"] = jsonFloat(sample.Value)
+			}
+			samples = append(samples, item)
+		}
+		result[f.Name] = samples
+	}
+	return result
+}
+
+// jsonFloat JSON 不支持 NaN 和 Inf，转换为字符串
+func jsonFloat(v float64) any {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return formatFloat(v)
+	}
+	return v
+}
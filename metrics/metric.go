@@ -0,0 +1,128 @@
+package metrics
+
+import (
+	"math"
+	"sort"
+	"sync/atomic"
+)
+
+// Counter 只增不减的计数器
+type Counter struct {
+	bits atomic.Uint64
+}
+
+// Inc 加 1
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+// Add 增加 v，v 为负数时忽略
+func (c *Counter) Add(v float64) {
+	if v < 0 {
+		return
+	}
+	addFloat(&c.bits, v)
+}
+
+// Value 当前值
+func (c *Counter) Value() float64 {
+	return math.Float64frombits(c.bits.Load())
+}
+
+// Gauge 可增可减的瞬时值
+type Gauge struct {
+	bits atomic.Uint64
+}
+
+// Set 设置为 v
+func (g *Gauge) Set(v float64) {
+	g.bits.Store(math.Float64bits(v))
+}
+
+// Add 增加 v，v 可以为负数
+func (g *Gauge) Add(v float64) {
+	addFloat(&g.bits, v)
+}
+
+// Inc 加 1
+func (g *Gauge) Inc() {
+	g.Add(1)
+}
+
+// Dec 减 1
+func (g *Gauge) Dec() {
+	g.Add(-1)
+}
+
+// Value 当前值
+func (g *Gauge) Value() float64 {
+	return math.Float64frombits(g.bits.Load())
+}
+
+// Histogram 固定桶的直方图
+type Histogram struct {
+	upperBounds []float64       // 各个桶的上界，升序，不包含 +Inf
+	counts      []atomic.Uint64 // 各个桶（非累计）的计数，最后一个对应 +Inf
+	sum         atomic.Uint64
+	count       atomic.Uint64
+}
+
+func newHistogram(buckets []float64) *Histogram {
+	return &Histogram{
+		upperBounds: buckets,
+		counts:      make([]atomic.Uint64, len(buckets)+1),
+	}
+}
+
+// Observe 记录一个观测值，NaN 会被忽略
+func (h *Histogram) Observe(v float64) {
+	if math.IsNaN(v) {
+		return
+	}
+	i := sort.SearchFloat64s(h.upperBounds, v)
+	h.counts[i].Add(1)
+	addFloat(&h.sum, v)
+	h.count.Add(1)
+}
+
+// HistogramValue 直方图快照
+type HistogramValue struct {
+	Buckets []Bucket // 累计计数的桶，最后一个上界为 +Inf
+	Sum     float64
+	Count   uint64
+}
+
+// Bucket 直方图的一个桶
+type Bucket struct {
+	UpperBound float64
+	Count      uint64 // 小于等于 UpperBound 的观测值个数（累计）
+}
+
+// Value 返回直方图快照，并发写入时各字段之间可能有细微的不一致
+func (h *Histogram) Value() HistogramValue {
+	v := HistogramValue{
+		Buckets: make([]Bucket, len(h.counts)),
+		Sum:     math.Float64frombits(h.sum.Load()),
+		Count:   h.count.Load(),
+	}
+	var cumulative uint64
+	for i := range h.counts {
+		cumulative += h.counts[i].Load()
+		upper := math.Inf(1)
+		if i < len(h.upperBounds) {
+			upper = h.upperBounds[i]
+		}
+		v.Buckets[i] = Bucket{UpperBound: upper, Count: cumulative}
+	}
+	return v
+}
+
+// addFloat 以 CAS 的方式给以 bits 保存的 float64 加上 v
+func addFloat(bits *atomic.Uint64, v float64) {
+	for {
+		old := bits.Load()
+		if bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+v)) {
+			return
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// PrometheusContentType Prometheus 文本格式的 Content-Type
+	PrometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+	// OpenMetricsContentType OpenMetrics 文本格式的 Content-Type
+	OpenMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+)
+
+// WritePrometheus 以 Prometheus 文本格式输出所有指标
+func (r *Registry) WritePrometheus(w io.Writer) error {
+	return writeText(w, r.Snapshot(), false)
+}
+
+// WriteOpenMetrics 以 OpenMetrics 文本格式输出所有指标，
+// 计数器的指标名去掉 _total 后缀，样本名带 _total 后缀，以 "# EOF" 结尾
+func (r *Registry) WriteOpenMetrics(w io.Writer) error {
+	return writeText(w, r.Snapshot(), true)
+}
+
+// Handler 返回输出指标的 http.Handler，Accept 包含 application/openmetrics-text 时输出 OpenMetrics，否则输出 Prometheus 文本格式
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, req *http.Request) {
+			if acceptsOpenMetrics(req.Header.Get("Accept")) {
+				w.Header().Set("Content-Type", OpenMetricsContentType)
+				_ = r.WriteOpenMetrics(w)
+				return
+			}
+			w.Header().Set("Content-Type", PrometheusContentType)
+			_ = r.WritePrometheus(w)
+		},
+	)
+}
+
+// acceptsOpenMetrics 判断 Accept 是否接受 OpenMetrics，忽略 q=0 的条目
+func acceptsOpenMetrics(accept string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || mediaType != "application/openmetrics-text" {
+			continue
+		}
+		if q, err := strconv.ParseFloat(params["q"], 64); err == nil && q <= 0 {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// writeText 输出 Prometheus 或 OpenMetrics 文本格式
+func writeText(w io.Writer, snap Snapshot, openMetrics bool) error {
+	bw := bufio.NewWriter(w)
+	for _, f := range snap.Families {
+		name, sampleName := f.Name, f.Name
+		help := escapeHelp(f.Help)
+		if openMetrics {
+			help = escapeLabel(f.Help)
+			if f.Kind == KindCounter {
+				name = strings.TrimSuffix(f.Name, "_total")
+				sampleName = name + "_total"
+			}
+		}
+		bw.WriteString("# HELP " + name + " " + help + "\n")
+		bw.WriteString("# TYPE " + name + " " + string(f.Kind) + "\n")
+		for _, s := range f.Samples {
+			if s.Histogram == nil {
+				writeSample(bw, sampleName, f.Labels, s.LabelValues, "", "", s.Value)
+				continue
+			}
+			for _, b := range s.Histogram.Buckets {
+				writeSample(bw, name+"_bucket", f.Labels, s.LabelValues, "le", formatFloat(b.UpperBound), float64(b.Count))
+			}
+			writeSample(bw, name+"_sum", f.Labels, s.LabelValues, "", "", s.Histogram.Sum)
+			writeSample(bw, name+"_count", f.Labels, s.LabelValues, "", "", float64(s.Histogram.Count))
+		}
+	}
+	if openMetrics {
+		bw.WriteString("# EOF\n")
+	}
+	return bw.Flush()
+}
+
+// writeSample 输出一行样本，extraName 非空时追加一个额外的标签（如直方图的 le）
+func writeSample(w *bufio.Writer, name string, labels, values []string, extraName, extraValue string, v float64) {
+	w.WriteString(name)
+	if len(labels) > 0 || extraName != "" {
+		w.WriteByte('{')
+		for i, l := range labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(l + `="` + escapeLabel(values[i]) + `"`)
+		}
+		if extraName != "" {
+			if len(labels) > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(extraName + `="` + extraValue + `"`)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(v))
+	w.WriteByte('\n')
+}
+
+// formatFloat 按 Prometheus 的习惯格式化数值
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
@@ -0,0 +1,284 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Kind 指标类型
+type Kind string
+
+const (
+	KindCounter   Kind = "counter"
+	KindGauge     Kind = "gauge"
+	KindHistogram Kind = "histogram"
+)
+
+// DefaultRegistry 默认的指标注册表
+var DefaultRegistry = NewRegistry()
+
+// Registry 指标注册表，注册时加锁，记录指标时只有原子操作
+type Registry struct {
+	mu       sync.RWMutex
+	families map[string]*family
+}
+
+// NewRegistry 创建指标注册表
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]*family)}
+}
+
+// family 同名指标的所有时间序列
+type family struct {
+	name    string
+	help    string
+	kind    Kind
+	labels  []string
+	buckets []float64
+	// exponential 和 scale 用于指数桶直方图
+	exponential bool
+	scale       int
+	series      sync.Map // 标签值拼接后的 key -> *series
+	newFn       func() any
+}
+
+// series 一组标签值对应的指标
+type series struct {
+	labelValues []string
+	metric      any
+}
+
+// labelSep 拼接标签值时使用的分隔符，不会出现在合法的 UTF-8 字符串中
+const labelSep = "\xff"
+
+// with 返回标签值对应的指标，不存在时创建
+func (f *family) with(values []string) any {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s 需要 %d 个标签值，实际为 %d 个", f.name, len(f.labels), len(values)))
+	}
+	key := strings.Join(values, labelSep)
+	if s, ok := f.series.Load(key); ok {
+		return s.(*series).metric
+	}
+	s, _ := f.series.LoadOrStore(key, &series{labelValues: slices.Clone(values), metric: f.newFn()})
+	return s.(*series).metric
+}
+
+// sortedSeries 按标签值排序的时间序列，用于稳定的输出顺序
+func (f *family) sortedSeries() []*series {
+	var list []*series
+	f.series.Range(
+		func(_, value any) bool {
+			list = append(list, value.(*series))
+			return true
+		},
+	)
+	sort.Slice(
+		list, func(i, j int) bool {
+			return slices.Compare(list[i].labelValues, list[j].labelValues) < 0
+		},
+	)
+	return list
+}
+
+// sameDefinition 判断两个指标的类型、标签和桶是否相同
+func (f *family) sameDefinition(other *family) bool {
+	return f.kind == other.kind &&
+		slices.Equal(f.labels, other.labels) &&
+		slices.Equal(f.buckets, other.buckets) &&
+		f.exponential == other.exponential &&
+		f.scale == other.scale
+}
+
+// register 注册指标，同名且类型、标签、桶都相同时返回已有的指标，否则 panic
+func (r *Registry) register(f *family) *family {
+	if !validName(f.name) {
+		panic(fmt.Sprintf("metrics: 无效的指标名 %q", f.name))
+	}
+	for _, l := range f.labels {
+		if !validName(l) || strings.HasPrefix(l, "__") || l == "le" {
+			panic(fmt.Sprintf("metrics: 无效的标签名 %q", l))
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if existing, ok := r.families[f.name]; ok {
+		if !existing.sameDefinition(f) {
+			panic(fmt.Sprintf("metrics: 指标 %s 重复注册且定义不一致", f.name))
+		}
+		return existing
+	}
+	r.families[f.name] = f
+	return f
+}
+
+// sortedFamilies 按名称排序的所有指标
+func (r *Registry) sortedFamilies() []*family {
+	r.mu.RLock()
+	list := make([]*family, 0, len(r.families))
+	for _, f := range r.families {
+		list = append(list, f)
+	}
+	r.mu.RUnlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].name < list[j].name })
+	return list
+}
+
+// CounterVec 带标签的计数器
+type CounterVec struct {
+	f *family
+}
+
+// NewCounterVec 注册带标签的计数器
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	f := r.register(
+		&family{
+			name:   name,
+			help:   help,
+			kind:   KindCounter,
+			labels: labels,
+			newFn:  func() any { return &Counter{} },
+		},
+	)
+	return &CounterVec{f: f}
+}
+
+// NewCounter 注册不带标签的计数器
+func (r *Registry) NewCounter(name, help string) *Counter {
+	return r.NewCounterVec(name, help).With()
+}
+
+// With 返回标签值对应的计数器，标签值的顺序与注册时一致
+func (v *CounterVec) With(values ...string) *Counter {
+	return v.f.with(values).(*Counter)
+}
+
+// GaugeVec 带标签的瞬时值
+type GaugeVec struct {
+	f *family
+}
+
+// NewGaugeVec 注册带标签的瞬时值
+func (r *Registry) NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	f := r.register(
+		&family{
+			name:   name,
+			help:   help,
+			kind:   KindGauge,
+			labels: labels,
+			newFn:  func() any { return &Gauge{} },
+		},
+	)
+	return &GaugeVec{f: f}
+}
+
+// NewGauge 注册不带标签的瞬时值
+func (r *Registry) NewGauge(name, help string) *Gauge {
+	return r.NewGaugeVec(name, help).With()
+}
+
+// With 返回标签值对应的瞬时值
+func (v *GaugeVec) With(values ...string) *Gauge {
+	return v.f.with(values).(*Gauge)
+}
+
+// HistogramVec 带标签的直方图
+type HistogramVec struct {
+	f *family
+}
+
+// NewHistogramVec 注册带标签的直方图，buckets 为各个桶的上界，会自动排序并去掉 +Inf
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	buckets = normalizeBuckets(buckets)
+	f := r.register(
+		&family{
+			name:    name,
+			help:    help,
+			kind:    KindHistogram,
+			labels:  labels,
+			buckets: buckets,
+			newFn:   func() any { return newHistogram(buckets) },
+		},
+	)
+	return &HistogramVec{f: f}
+}
+
+// NewHistogram 注册不带标签的直方图
+func (r *Registry) NewHistogram(name, help string, buckets []float64) *Histogram {
+	return r.NewHistogramVec(name, help, buckets).With()
+}
+
+// With 返回标签值对应的直方图
+func (v *HistogramVec) With(values ...string) *Histogram {
+	return v.f.with(values).(*Histogram)
+}
+
+// DefBuckets 默认的延迟桶（秒）
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// LinearBuckets 生成 count 个桶，从 start 开始每个桶增加 width
+func LinearBuckets(start, width float64, count int) []float64 {
+	if count < 1 {
+		panic("metrics: LinearBuckets 的 count 必须大于 0")
+	}
+	buckets := make([]float64, count)
+	for i := range buckets {
+		buckets[i] = start + float64(i)*width
+	}
+	return buckets
+}
+
+// ExponentialBuckets 生成 count 个桶，从 start 开始每个桶乘以 factor
+func ExponentialBuckets(start, factor float64, count int) []float64 {
+	if count < 1 || start <= 0 || factor <= 1 {
+		panic("metrics: ExponentialBuckets 需要 count > 0、start > 0 且 factor > 1")
+	}
+	buckets := make([]float64, count)
+	for i := range buckets {
+		buckets[i] = start
+		start *= factor
+	}
+	return buckets
+}
+
+// ExponentialBucketsRange 生成 count 个从 low 到 high 按指数增长的桶
+func ExponentialBucketsRange(low, high float64, count int) []float64 {
+	if count < 2 || low <= 0 || high <= low {
+		panic("metrics: ExponentialBucketsRange 需要 count > 1 且 0 < low < high")
+	}
+	factor := math.Pow(high/low, 1/float64(count-1))
+	return ExponentialBuckets(low, factor, count)
+}
+
+// normalizeBuckets 复制、排序并去重，去掉 +Inf
+func normalizeBuckets(buckets []float64) []float64 {
+	if len(buckets) == 0 {
+		buckets = DefBuckets
+	}
+	result := slices.Clone(buckets)
+	sort.Float64s(result)
+	result = slices.Compact(result)
+	for len(result) > 0 && math.IsInf(result[len(result)-1], 1) {
+		result = result[:len(result)-1]
+	}
+	return result
+}
+
+// validName 判断指标名和标签名是否合法：[a-zA-Z_:][a-zA-Z0-9_:]*
+func validName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		if r == '_' || r == ':' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || i > 0 && r >= '0' && r <= '9' {
+			continue
+		}
+		return false
+	}
+	return true
+}
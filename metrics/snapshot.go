@@ -0,0 +1,104 @@
+package metrics
+
+import (
+	"slices"
+)
+
+// Snapshot 注册表在某一时刻的所有指标，主要用于测试和 expvar
+type Snapshot struct {
+	Families []FamilySnapshot
+}
+
+// FamilySnapshot 同名指标的快照
+type FamilySnapshot struct {
+	Name    string
+	Help    string
+	Kind    Kind
+	Labels  []string
+	Samples []Sample
+}
+
+// Sample 一组标签值对应的指标值，直方图的 Value 为观测次数
+type Sample struct {
+	LabelValues []string
+	Value       float64
+	Histogram   *HistogramValue
+}
+
+// histogramValuer 固定桶和指数桶直方图
+type histogramValuer interface {
+	Value() HistogramValue
+}
+
+// Snapshot 返回所有指标的快照，按指标名和标签值排序，不包含还没有任何时间序列的指标
+func (r *Registry) Snapshot() Snapshot {
+	var snap Snapshot
+	for _, f := range r.sortedFamilies() {
+		list := f.sortedSeries()
+		if len(list) == 0 {
+			continue
+		}
+		fs := FamilySnapshot{
+			Name:    f.name,
+			Help:    f.help,
+			Kind:    f.kind,
+			Labels:  slices.Clone(f.labels),
+			Samples: make([]Sample, 0, len(list)),
+		}
+		for _, s := range list {
+			sample := Sample{LabelValues: slices.Clone(s.labelValues)}
+			switch m := s.metric.(type) {
+			case *Counter:
+				sample.Value = m.Value()
+			case *Gauge:
+				sample.Value = m.Value()
+			case histogramValuer:
+				v := m.Value()
+				sample.Value = float64(v.Count)
+				sample.Histogram = &v
+			}
+			fs.Samples = append(fs.Samples, sample)
+		}
+		snap.Families = append(snap.Families, fs)
+	}
+	return snap
+}
+
+// Family 按名称查找指标
+func (s Snapshot) Family(name string) (FamilySnapshot, bool) {
+	for _, f := range s.Families {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return FamilySnapshot{}, false
+}
+
+// Get 按名称和标签值查找指标，标签值的顺序与注册时一致
+func (s Snapshot) Get(name string, labelValues ...string) (Sample, bool) {
+	f, ok := s.Family(name)
+	if !ok {
+		return Sample{}, false
+	}
+	for _, sample := range f.Samples {
+		if slices.Equal(sample.LabelValues, labelValues) {
+			return sample, true
+		}
+	}
+	return Sample{}, false
+}
+
+// Value 返回计数器或瞬时值的当前值、直方图的观测次数，不存在时返回 0
+func (s Snapshot) Value(name string, labelValues ...string) float64 {
+	sample, _ := s.Get(name, labelValues...)
+	return sample.Value
+}
+
+// LabelMap 返回样本的标签名到标签值的映射
+func (f FamilySnapshot) LabelMap(sample Sample) map[string]string {
+	labels := make(map[string]string, len(f.Labels))
+	for i, l := range f.Labels {
+		labels[l] = sample.LabelValues[i]
+	}
+	return labels
+}
@@ -0,0 +1,75 @@
+/**
+  @author: 35840
+  @date: 2026/10/19
+  @desc: 请求内的分阶段计时
+**/
+
+package common
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const TimingKey = "requestTiming"
+
+// Phase 一个计时阶段
+type Phase struct {
+	Name     string
+	Duration time.Duration
+}
+
+// RequestTiming 请求内的分阶段计时，可以在多个 goroutine 中同时使用
+type RequestTiming struct {
+	mu     sync.Mutex
+	start  time.Time
+	phases []Phase
+}
+
+// GetRequestTiming 获取请求的计时器，不存在时创建，起始时间与 GetStartTime 相同
+func GetRequestTiming(c *gin.Context) *RequestTiming {
+	if val, ok := c.Get(TimingKey); ok {
+		if timing, ok := val.(*RequestTiming); ok {
+			return timing
+		}
+	}
+	timing := &RequestTiming{start: GetStartTime(c)}
+	c.Set(TimingKey, timing)
+	return timing
+}
+
+// StartPhase 开始一个阶段，调用返回的函数结束该阶段，如 defer common.StartPhase(c, "db")()
+func StartPhase(c *gin.Context, name string) func() {
+	timing := GetRequestTiming(c)
+	begin := time.Now()
+	return func() {
+		timing.Add(name, time.Since(begin))
+	}
+}
+
+// Start 返回请求的开始时间
+func (t *RequestTiming) Start() time.Time {
+	return t.start
+}
+
+// Add 记录一个阶段的耗时，同名阶段的耗时累加，阶段按第一次出现的顺序排列
+func (t *RequestTiming) Add(name string, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i := range t.phases {
+		if t.phases[i].Name == name {
+			t.phases[i].Duration += d
+			return
+		}
+	}
+	t.phases = append(t.phases, Phase{Name: name, Duration: d})
+}
+
+// Phases 返回所有阶段的副本
+func (t *RequestTiming) Phases() []Phase {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Phase(nil), t.phases...)
+}
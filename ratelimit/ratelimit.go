@@ -0,0 +1,167 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Limit 限流规则：每个 Period 内允许 Rate 次请求
+type Limit struct {
+	Rate   int
+	Period time.Duration
+	// Burst 令牌桶的容量，允许的突发请求数，默认等于 Rate，滑动窗口算法不使用
+	Burst int
+}
+
+// PerSecond 每秒 rate 次
+func PerSecond(rate int) Limit {
+	return Limit{Rate: rate, Period: time.Second}
+}
+
+// PerMinute 每分钟 rate 次
+func PerMinute(rate int) Limit {
+	return Limit{Rate: rate, Period: time.Minute}
+}
+
+// PerHour 每小时 rate 次
+func PerHour(rate int) Limit {
+	return Limit{Rate: rate, Period: time.Hour}
+}
+
+// burst 返回令牌桶容量
+func (l Limit) burst() int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+	return l.Rate
+}
+
+// valid 判断规则是否有效
+func (l Limit) valid() bool {
+	return l.Rate > 0 && l.Period > 0
+}
+
+// Result 一次限流判断的结果
+type Result struct {
+	// Allowed 是否允许本次请求
+	Allowed bool
+	// Limit 窗口内允许的请求数（令牌桶为容量）
+	Limit int
+	// Remaining 本次请求之后窗口内剩余的请求数
+	Remaining int
+	// ResetAfter 配额完全恢复还需要的时间
+	ResetAfter time.Duration
+	// RetryAfter 被拒绝时距离下一次允许的时间，允许时为 0
+	RetryAfter time.Duration
+}
+
+// Limiter 限流器
+type Limiter interface {
+	// Allow 判断 key 的本次请求是否允许，并消耗一次配额
+	Allow(ctx context.Context, key string) (Result, error)
+	// Limit 返回限流规则，用于输出 RateLimit-Policy
+	Limit() Limit
+}
+
+// TokenBucket 令牌桶：以 Rate/Period 的速度补充令牌，最多积累 Burst 个，允许突发
+type TokenBucket struct {
+	store Store
+	limit Limit
+	now   func() time.Time
+}
+
+// NewTokenBucket 创建令牌桶限流器，limit 无效时 panic
+func NewTokenBucket(store Store, limit Limit) *TokenBucket {
+	if !limit.valid() {
+		panic("ratelimit: Rate 和 Period 必须大于 0")
+	}
+	return &TokenBucket{store: store, limit: limit, now: time.Now}
+}
+
+// Limit 返回限流规则
+func (b *TokenBucket) Limit() Limit {
+	return b.limit
+}
+
+// Allow 消耗一个令牌
+func (b *TokenBucket) Allow(ctx context.Context, key string) (Result, error) {
+	burst := float64(b.limit.burst())
+	perToken := b.limit.Period / time.Duration(b.limit.Rate)
+	// 桶从空到满需要的时间，之后状态与新建的一样，可以过期删除
+	ttl := time.Duration(burst) * perToken
+	now := b.now()
+
+	var result Result
+	err := b.store.Update(
+		ctx, key, ttl, func(state *State) {
+			if state.Last.IsZero() {
+				state.Tokens = burst
+			} else if elapsed := now.Sub(state.Last); elapsed > 0 {
+				state.Tokens = math.Min(burst, state.Tokens+float64(elapsed)/float64(perToken))
+			}
+			state.Last = now
+
+			result = Result{Limit: int(burst)}
+			if state.Tokens >= 1 {
+				state.Tokens--
+				result.Allowed = true
+			} else {
+				result.RetryAfter = time.Duration((1 - state.Tokens) * float64(perToken))
+			}
+			result.Remaining = int(state.Tokens)
+			result.ResetAfter = time.Duration((burst - state.Tokens) * float64(perToken))
+		},
+	)
+	return result, err
+}
+
+// SlidingWindowLog 滑动窗口日志：记录窗口内每次请求的时间，任意 Period 长度的窗口内不超过 Rate 次，
+// 比令牌桶更精确，但每个 key 需要保存最多 Rate 个时间戳
+type SlidingWindowLog struct {
+	store Store
+	limit Limit
+	now   func() time.Time
+}
+
+// NewSlidingWindowLog 创建滑动窗口日志限流器，limit 无效时 panic
+func NewSlidingWindowLog(store Store, limit Limit) *SlidingWindowLog {
+	if !limit.valid() {
+		panic("ratelimit: Rate 和 Period 必须大于 0")
+	}
+	return &SlidingWindowLog{store: store, limit: limit, now: time.Now}
+}
+
+// Limit 返回限流规则
+func (w *SlidingWindowLog) Limit() Limit {
+	return w.limit
+}
+
+// Allow 窗口内请求数未达到上限时记录本次请求
+func (w *SlidingWindowLog) Allow(ctx context.Context, key string) (Result, error) {
+	now := w.now()
+	windowStart := now.Add(-w.limit.Period)
+
+	var result Result
+	err := w.store.Update(
+		ctx, key, w.limit.Period, func(state *State) {
+			// 去掉窗口之外的记录
+			i := 0
+			for i < len(state.Timestamps) && !state.Timestamps[i].After(windowStart) {
+				i++
+			}
+			state.Timestamps = state.Timestamps[i:]
+
+			result = Result{Limit: w.limit.Rate}
+			if len(state.Timestamps) < w.limit.Rate {
+				state.Timestamps = append(state.Timestamps, now)
+				result.Allowed = true
+			} else {
+				result.RetryAfter = state.Timestamps[0].Sub(windowStart)
+			}
+			result.Remaining = w.limit.Rate - len(state.Timestamps)
+			result.ResetAfter = state.Timestamps[len(state.Timestamps)-1].Sub(windowStart)
+		},
+	)
+	return result, err
+}
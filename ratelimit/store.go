@@ -0,0 +1,139 @@
+package ratelimit
+
+import (
+	"context"
+	"hash/maphash"
+	"sync"
+	"time"
+)
+
+// State 一个 key 的限流状态，字段均可序列化，便于在 Redis 等外部存储中保存
+type State struct {
+	// Tokens 令牌桶当前的令牌数
+	Tokens float64 `json:"tokens,omitempty"`
+	// Last 令牌桶上次更新的时间
+	Last time.Time `json:"last,omitempty"`
+	// Timestamps 滑动窗口内各次请求的时间，升序
+	Timestamps []time.Time `json:"timestamps,omitempty"`
+}
+
+// Store 限流状态存储
+type Store interface {
+	// Update 原子地读取、修改并保存 key 的状态，不存在或已过期时 fn 收到零值，
+	// 保存后 ttl 内没有再次更新则可以删除。外部存储可以用乐观锁或脚本实现
+	Update(ctx context.Context, key string, ttl time.Duration, fn func(state *State)) error
+}
+
+// MemoryStoreConfig 内存存储配置
+type MemoryStoreConfig struct {
+	// Shards 分片数量，默认 64，分片越多锁竞争越少
+	Shards int
+	// CleanupInterval 清理过期 key 的间隔，默认 1 分钟
+	CleanupInterval time.Duration
+}
+
+// MemoryStore 分片加锁的内存存储，后台定期清理过期的 key，不再使用时需要调用 Close
+type MemoryStore struct {
+	seed   maphash.Seed
+	shards []memoryShard
+	stop   chan struct{}
+	once   sync.Once
+}
+
+type memoryShard struct {
+	mu      sync.Mutex
+	entries map[string]*memoryEntry
+}
+
+type memoryEntry struct {
+	state   State
+	expires time.Time
+}
+
+// NewMemoryStore 使用默认配置创建内存存储
+func NewMemoryStore() *MemoryStore {
+	return NewMemoryStoreWithConfig(MemoryStoreConfig{})
+}
+
+// NewMemoryStoreWithConfig 按配置创建内存存储
+func NewMemoryStoreWithConfig(cfg MemoryStoreConfig) *MemoryStore {
+	if cfg.Shards <= 0 {
+		cfg.Shards = 64
+	}
+	if cfg.CleanupInterval <= 0 {
+		cfg.CleanupInterval = time.Minute
+	}
+	s := &MemoryStore{
+		seed:   maphash.MakeSeed(),
+		shards: make([]memoryShard, cfg.Shards),
+		stop:   make(chan struct{}),
+	}
+	for i := range s.shards {
+		s.shards[i].entries = make(map[string]*memoryEntry)
+	}
+	go s.janitor(cfg.CleanupInterval)
+	return s
+}
+
+// Update 在分片锁内修改 key 的状态
+func (s *MemoryStore) Update(_ context.Context, key string, ttl time.Duration, fn func(state *State)) error {
+	shard := &s.shards[maphash.String(s.seed, key)%uint64(len(s.shards))]
+	now := time.Now()
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	entry, ok := shard.entries[key]
+	if !ok || now.After(entry.expires) {
+		entry = &memoryEntry{}
+		shard.entries[key] = entry
+	}
+	fn(&entry.state)
+	entry.expires = now.Add(ttl)
+	return nil
+}
+
+// Len 返回当前保存的 key 数量，包含已过期但还没有清理的
+func (s *MemoryStore) Len() int {
+	n := 0
+	for i := range s.shards {
+		shard := &s.shards[i]
+		shard.mu.Lock()
+		n += len(shard.entries)
+		shard.mu.Unlock()
+	}
+	return n
+}
+
+// Close 停止后台清理
+func (s *MemoryStore) Close() error {
+	s.once.Do(func() { close(s.stop) })
+	return nil
+}
+
+// janitor 定期删除过期的 key
+func (s *MemoryStore) janitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case now := <-ticker.C:
+			s.deleteExpired(now)
+		}
+	}
+}
+
+// deleteExpired 逐个分片删除过期的 key，避免长时间持有所有锁
+func (s *MemoryStore) deleteExpired(now time.Time) {
+	for i := range s.shards {
+		shard := &s.shards[i]
+		shard.mu.Lock()
+		for key, entry := range shard.entries {
+			if now.After(entry.expires) {
+				delete(shard.entries, key)
+			}
+		}
+		shard.mu.Unlock()
+	}
+}
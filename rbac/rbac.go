@@ -0,0 +1,153 @@
+package rbac
+
+import (
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Permission 权限，格式为 "资源:操作"，如 "orders:read"。
+// 资源支持 path.Match 通配符和 "/**" 前缀匹配，如 "projects/*/docs:write"、"billing/**:read"，
+// "*" 表示所有资源或所有操作
+type Permission string
+
+// split 拆分为资源和操作，没有操作时操作为 "*"
+func (p Permission) split() (resource, action string) {
+	i := strings.LastIndexByte(string(p), ':')
+	if i < 0 {
+		return string(p), "*"
+	}
+	return string(p[:i]), string(p[i+1:])
+}
+
+// Matches 判断授予的权限 p 是否覆盖需要的权限 required
+func (p Permission) Matches(required Permission) bool {
+	grantedRes, grantedAct := p.split()
+	requiredRes, requiredAct := required.split()
+	if grantedAct != "*" && grantedAct != requiredAct {
+		return false
+	}
+	return matchResource(grantedRes, requiredRes)
+}
+
+// matchResource 资源匹配，"*" 匹配所有
+func matchResource(pattern, resource string) bool {
+	if pattern == "*" || pattern == resource {
+		return true
+	}
+	if prefix, ok := strings.CutSuffix(pattern, "/**"); ok {
+		return resource == prefix || strings.HasPrefix(resource, prefix+"/")
+	}
+	ok, err := path.Match(pattern, resource)
+	return err == nil && ok
+}
+
+// Role 角色，可以继承其他角色的权限
+type Role struct {
+	Name        string       `json:"name"`
+	Permissions []Permission `json:"permissions"`
+	Inherits    []string     `json:"inherits,omitempty"`
+}
+
+// Subject 请求主体
+type Subject struct {
+	ID    string
+	Roles []string
+	// Permissions 直接授予的权限，如 API key 的授权范围
+	Permissions []Permission
+	// Attributes 用于自定义判断的属性，如 {"tenant": "t1"}
+	Attributes map[string]any
+}
+
+// RBAC 角色和权限表，可以在运行时修改，可以在多个 goroutine 中同时使用
+type RBAC struct {
+	mu    sync.RWMutex
+	roles map[string]Role
+}
+
+// New 创建权限表
+func New(roles ...Role) *RBAC {
+	r := &RBAC{roles: make(map[string]Role, len(roles))}
+	for _, role := range roles {
+		r.roles[role.Name] = role
+	}
+	return r
+}
+
+// SetRole 添加或替换角色
+func (r *RBAC) SetRole(role Role) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.roles[role.Name] = role
+}
+
+// RemoveRole 删除角色
+func (r *RBAC) RemoveRole(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.roles, name)
+}
+
+// Roles 返回所有角色，按名称排序
+func (r *RBAC) Roles() []Role {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	list := make([]Role, 0, len(r.roles))
+	for _, role := range r.roles {
+		list = append(list, role)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Permissions 返回角色（包括继承的角色）拥有的所有权限，循环继承会被忽略
+func (r *RBAC) Permissions(roles ...string) []Permission {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var perms []Permission
+	seen := make(map[string]bool)
+	var walk func(name string)
+	walk = func(name string) {
+		if seen[name] {
+			return
+		}
+		seen[name] = true
+		role, ok := r.roles[name]
+		if !ok {
+			return
+		}
+		perms = append(perms, role.Permissions...)
+		for _, parent := range role.Inherits {
+			walk(parent)
+		}
+	}
+	for _, name := range roles {
+		walk(name)
+	}
+	slices.Sort(perms)
+	return slices.Compact(perms)
+}
+
+// Allowed 判断主体是否拥有所有需要的权限
+func (r *RBAC) Allowed(sub Subject, required ...Permission) bool {
+	granted := append(r.Permissions(sub.Roles...), sub.Permissions...)
+	for _, need := range required {
+		if !slices.ContainsFunc(granted, func(p Permission) bool { return p.Matches(need) }) {
+			return false
+		}
+	}
+	return true
+}
+
+// RolesAllowed 返回拥有所有需要的权限的角色，用于审计
+func (r *RBAC) RolesAllowed(required ...Permission) []string {
+	var names []string
+	for _, role := range r.Roles() {
+		if r.Allowed(Subject{Roles: []string{role.Name}}, required...) {
+			names = append(names, role.Name)
+		}
+	}
+	return names
+}